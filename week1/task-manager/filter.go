@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+
+	"task-api/filter"
+	"task-api/models"
+)
+
+// The filter language used by `list -filter` is task-api's (see package
+// task-api/filter), so a filter means the same on the command line and
+// in GET /tasks?filter=...:
+//
+//	completed:false tag:work due<2026-11-01 "quarterly report"
+//
+// Dates given as YYYY-MM-DD are whole local days.
+
+// SyntaxError reports where a filter could not be parsed.
+type SyntaxError = filter.SyntaxError
+
+// FilterExpr is a parsed filter.
+type FilterExpr struct {
+	expr filter.Expr
+}
+
+// ParseFilter parses a filter expression. An empty filter matches every
+// task.
+func ParseFilter(input string) (FilterExpr, error) {
+	expr, err := filter.ParseIn(input, time.Local)
+	if err != nil {
+		return FilterExpr{}, err
+	}
+	return FilterExpr{expr: expr}, nil
+}
+
+func (f FilterExpr) Match(task *Task) bool {
+	return f.expr.Match(&models.Task{
+		ID:          task.ID,
+		Description: task.Description,
+		Completed:   task.Completed,
+		CreatedAt:   task.CreatedAt,
+		CompletedAt: task.CompletedAt,
+		Priority:    models.Priority(task.Priority),
+		DueAt:       task.DueAt,
+		Tags:        task.Tags,
+		Notes:       task.Notes,
+		DeletedAt:   task.DeletedAt,
+	})
+}
+
+func (f FilterExpr) String() string { return f.expr.String() }
@@ -1,34 +1,132 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// defaultPurgeDays is how long deleted tasks stay in the trash.
+const defaultPurgeDays = 30
+
 func printUsage() {
 	fmt.Print(`Usage:
-go run . add 'Buy Groceries'
-go run . list
+go run . [--project name] <command>
+
+go run . add 'Buy Groceries' [-priority high] [-due 2026-11-01] [-tags work,home] [-notes 'text'] [-assignee sam]
+go run . list [-filter 'completed:false tag:work due<2026-11-01 "quarterly report"']
 go run . complete 1
 go run . delete 2
+go run . trash
+go run . restore 2
+go run . purge [days]
 go run . search 'buy'
+
+--project limits every command to that project's tasks, and add puts
+new tasks in it. Only project tasks can take an -assignee.
+
+Deleted tasks stay in the trash for 30 days (set TASKS_PURGE_DAYS to
+change, 0 to keep them forever) before they are removed for good.
 `)
 }
 
-func handleAdd(tm *TaskManager, description string) {
-	tm.Add(description)
+// parseGlobalFlags reads the flags that come before the command, such as
+// --project, and returns the command and its arguments.
+func parseGlobalFlags(args []string) (project string, rest []string, err error) {
+	fs := flag.NewFlagSet("task-manager", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&project, "project", "", "only work on this project's tasks")
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSpace(project), fs.Args(), nil
+}
+
+// parseDue accepts a plain date or a full RFC 3339 timestamp.
+func parseDue(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
 }
 
-func handleList(tm *TaskManager) {
+// handleAdd creates a task; opts are the optional -priority, -due, -tags,
+// -notes and -assignee flags that follow the description.
+func handleAdd(tm *TaskManager, description string, opts ...string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	priority := fs.String("priority", "", "low, medium, high or urgent")
+	due := fs.String("due", "", "due date, e.g. 2026-11-01")
+	tags := fs.String("tags", "", "comma-separated tags")
+	notes := fs.String("notes", "", "free-form notes")
+	assignee := fs.String("assignee", "", "who the task is assigned to")
+	if err := fs.Parse(opts); err != nil {
+		return err
+	}
+
+	// Like the API, only project tasks can be assigned.
+	if strings.TrimSpace(*assignee) != "" && tm.Project == "" {
+		return fmt.Errorf("only project tasks can be assigned (use --project)")
+	}
+
+	if !validPriority(*priority) {
+		return fmt.Errorf("invalid priority %q (want one of %s)", *priority, strings.Join(priorities, ", "))
+	}
+
+	var dueAt *time.Time
+	if *due != "" {
+		t, err := parseDue(*due)
+		if err != nil {
+			return fmt.Errorf("invalid due date %q (want YYYY-MM-DD)", *due)
+		}
+		dueAt = &t
+	}
+
+	task := tm.Add(description)
+	task.Priority = *priority
+	task.DueAt = dueAt
+	task.Notes = *notes
+	task.Assignee = strings.TrimSpace(*assignee)
+	for _, tag := range strings.Split(*tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			task.Tags = append(task.Tags, tag)
+		}
+	}
+	return nil
+}
+
+// handleList prints the tasks; opts may hold -filter with a filter
+// expression (see ParseFilter).
+func handleList(tm *TaskManager, opts ...string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	filter := fs.String("filter", "", `filter expression, e.g. 'completed:false tag:work due<2026-11-01'`)
+	if err := fs.Parse(opts); err != nil {
+		return err
+	}
+	expr, err := ParseFilter(*filter)
+	if err != nil {
+		return err
+	}
+
 	fmt.Println("-----Task List-----")
-	tasks := tm.List()
+	var tasks []*Task
+	for _, task := range tm.List() {
+		if expr.Match(task) {
+			tasks = append(tasks, task)
+		}
+	}
 	if len(tasks) == 0 {
 		fmt.Println("No tasks found.")
-		return
+		return nil
 	}
 	for _, task := range tasks {
 		fmt.Println(task)
 	}
+	return nil
 }
 
 func handleComplete(tm *TaskManager, args string) error {
@@ -53,10 +151,55 @@ func handleDelete(tm *TaskManager, args string) error {
 	if err := tm.Delete(id); err != nil {
 		return err
 	}
-	fmt.Println("Task deleted.")
+	fmt.Printf("Task moved to trash. Undo with: go run . restore %d\n", id)
+	return nil
+}
+
+func handleTrash(tm *TaskManager) {
+	fmt.Println("-----Trash-----")
+	tasks := tm.Trash()
+	if len(tasks) == 0 {
+		fmt.Println("Trash is empty.")
+		return
+	}
+	for _, task := range tasks {
+		fmt.Printf("%s (deleted %s)\n", task, task.DeletedAt.Format("2006-01-02"))
+	}
+}
+
+func handleRestore(tm *TaskManager, args string) error {
+	id, err := strconv.Atoi(args)
+	if err != nil {
+		return fmt.Errorf("invalid ID: %v", err)
+	}
+
+	if err := tm.Restore(id); err != nil {
+		return err
+	}
+	fmt.Println("Task restored.")
 	return nil
 }
 
+// handlePurge permanently removes tasks that have been in the trash for
+// more than days days; 0 empties the trash.
+func handlePurge(tm *TaskManager, days int) {
+	n := tm.Purge(time.Now().AddDate(0, 0, -days))
+	fmt.Printf("Purged %d task(s).\n", n)
+}
+
+// purgeDays reads TASKS_PURGE_DAYS, the number of days deleted tasks are
+// kept before being purged automatically.
+func purgeDays(value string) (int, error) {
+	if value == "" {
+		return defaultPurgeDays, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("TASKS_PURGE_DAYS must be a whole number of days, got %q", value)
+	}
+	return days, nil
+}
+
 func handleSearch(tm *TaskManager, args string) {
 	results := tm.Search(args)
 	for _, task := range results {
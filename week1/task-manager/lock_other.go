@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// LockTasks is a no-op where flock is unavailable.
+func LockTasks(filename string) (func() error, error) {
+	return func() error { return nil }, nil
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// LockTasks takes an exclusive advisory lock on filename+".lock" so two
+// CLI invocations cannot interleave their load-modify-save cycles.
+func LockTasks(filename string) (func() error, error) {
+	f, err := os.OpenFile(filename+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() error {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return f.Close()
+	}, nil
+}
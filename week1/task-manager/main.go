@@ -3,59 +3,115 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 func main() {
-	if len(os.Args) < 2 {
+	project, args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if len(args) < 1 {
 		printUsage()
 		return
 	}
 
-	tasks, _ := LoadTasks(filename)
+	unlock, err := LockTasks(filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	defer unlock()
+
+	tasks, nextID, err := LoadTasks(filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
 	tm := NewTaskManager()
 	tm.Tasks = tasks
+	tm.NextID = nextID
 
-	if len(tasks) > 0 {
-		tm.NextID = tasks[len(tasks)-1].ID + 1
+	days, err := purgeDays(os.Getenv("TASKS_PURGE_DAYS"))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if days > 0 {
+		tm.Purge(time.Now().AddDate(0, 0, -days))
 	}
 
-	command := os.Args[1]
+	tm.Project = project
+
+	command := args[0]
 	switch command {
 	case "add":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: go run . add \"description\"")
 			return
 		}
-		handleAdd(tm, os.Args[2])
+		if err := handleAdd(tm, args[1], args[2:]...); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
 
 	case "complete":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: go run . complete <id>")
 			return
 		}
-		if err := handleComplete(tm, os.Args[2]); err != nil {
+		if err := handleComplete(tm, args[1]); err != nil {
 			fmt.Println("Error:", err)
 		}
 
 	case "delete":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: go run . delete <id>")
 			return
 		}
-		if err := handleDelete(tm, os.Args[2]); err != nil {
+		if err := handleDelete(tm, args[1]); err != nil {
 			fmt.Println("Error:", err)
 		}
 
+	case "trash":
+		handleTrash(tm)
+
+	case "restore":
+		if len(args) < 2 {
+			fmt.Println("Usage: go run . restore <id>")
+			return
+		}
+		if err := handleRestore(tm, args[1]); err != nil {
+			fmt.Println("Error:", err)
+		}
+
+	case "purge":
+		days := 0
+		if len(args) > 1 {
+			if days, err = strconv.Atoi(args[1]); err != nil || days < 0 {
+				fmt.Println("Usage: go run . purge [days]")
+				return
+			}
+		}
+		handlePurge(tm, days)
+
 	case "search":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			fmt.Println("Usage: go run . search <word>")
 			return
 		}
-		handleSearch(tm, os.Args[2])
+		handleSearch(tm, args[1])
 
 	case "list":
-		handleList(tm)
+		if err := handleList(tm, args[1:]...); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
 	}
 
-	SaveTasks(tm.Tasks, filename)
+	if err := SaveTasks(tm.Tasks, tm.NextID, filename); err != nil {
+		fmt.Println("Error:", err)
+	}
 }
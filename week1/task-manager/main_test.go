@@ -2,10 +2,12 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 // --- Task Manager Core Logic Tests (Manager Methods) ---
@@ -57,18 +59,92 @@ func TestManager_Delete(t *testing.T) {
 	tm.Add("Task B")
 	initialCount := len(tm.Tasks)
 
-	// Case 1: Delete existing task (ID 1)
+	// Case 1: Delete existing task (ID 1) moves it to the trash
 	if err := tm.Delete(1); err != nil {
 		t.Fatalf("Delete failed for existing task: %v", err)
 	}
-	if len(tm.Tasks) != initialCount-1 || tm.Tasks[0].ID != 2 {
-		t.Error("Delete failed: task was not removed or wrong task remained.")
+	if list := tm.List(); len(list) != initialCount-1 || list[0].ID != 2 {
+		t.Error("Delete failed: task was not hidden or wrong task remained.")
+	}
+	if trash := tm.Trash(); len(trash) != 1 || trash[0].ID != 1 || trash[0].DeletedAt == nil {
+		t.Errorf("Deleted task missing from trash: %v", trash)
 	}
 
-	// Case 2: Delete non-existent task (ID 99)
+	// Case 2: Delete non-existent task (ID 99), or one already deleted
 	if err := tm.Delete(99); err == nil {
 		t.Error("Delete succeeded unexpectedly for non-existent ID 99")
 	}
+	if err := tm.Delete(1); err == nil {
+		t.Error("Delete succeeded unexpectedly for a task already in the trash")
+	}
+
+	// Case 3: Deleted tasks are hidden from search and cannot be completed
+	if results := tm.Search("Task A"); len(results) != 0 {
+		t.Errorf("Search returned a deleted task: %v", results)
+	}
+	if err := tm.Complete(1); err == nil {
+		t.Error("Complete succeeded unexpectedly for a deleted task")
+	}
+}
+
+func TestManager_RestoreAndPurge(t *testing.T) {
+	tm := NewTaskManager()
+	tm.Add("Old mistake")
+	tm.Add("Recent mistake")
+	tm.Add("Keeper")
+	tm.Delete(1)
+	tm.Delete(2)
+	old := time.Now().AddDate(0, 0, -40)
+	tm.Tasks[0].DeletedAt = &old
+
+	// Case 1: Restore brings a task back, only once
+	if err := tm.Restore(2); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(tm.List()) != 2 {
+		t.Errorf("Expected 2 live tasks after restore, got %d", len(tm.List()))
+	}
+	if err := tm.Restore(2); err == nil {
+		t.Error("Restore succeeded unexpectedly for a live task")
+	}
+	if _, ok := tm.Restore(99).(TaskNotFoundError); !ok {
+		t.Error("Expected TaskNotFoundError restoring a missing task")
+	}
+
+	// Case 2: Purge only removes tasks deleted before the cutoff
+	tm.Delete(3)
+	if n := tm.Purge(time.Now().AddDate(0, 0, -30)); n != 1 {
+		t.Errorf("Expected 1 purged task, got %d", n)
+	}
+	if len(tm.Tasks) != 2 || len(tm.Trash()) != 1 {
+		t.Errorf("Unexpected tasks after purge: %v", tm.Tasks)
+	}
+}
+
+func TestManager_ProjectScope(t *testing.T) {
+	tm := NewTaskManager()
+	tm.Add("Personal errand")
+	tm.Project = "launch"
+	tm.Add("Write launch post")
+
+	// Case 1: New tasks join the selected project
+	if tm.Tasks[1].Project != "launch" || tm.Tasks[0].Project != "" {
+		t.Errorf("Expected only task 2 in launch, got %q and %q", tm.Tasks[0].Project, tm.Tasks[1].Project)
+	}
+
+	// Case 2: Listing and lookups only see the project's tasks
+	if tasks := tm.List(); len(tasks) != 1 || tasks[0].ID != 2 {
+		t.Errorf("Expected only task 2 in scope, got %v", tasks)
+	}
+	if err := tm.Complete(1); !errors.As(err, new(TaskNotFoundError)) {
+		t.Errorf("Expected task 1 to be out of scope, got %v", err)
+	}
+
+	// Case 3: Without a project every task is visible
+	tm.Project = ""
+	if len(tm.List()) != 2 {
+		t.Errorf("Expected 2 tasks without a project, got %d", len(tm.List()))
+	}
 }
 
 func TestManager_Search(t *testing.T) {
@@ -153,40 +229,70 @@ func TestPersistence_SaveLoadNextID(t *testing.T) {
 
 	// 1. Arrange & Act: Save tasks (IDs 1, 2)
 	originalTasks := []*Task{NewTask(1, "A"), NewTask(2, "B")}
-	if err := SaveTasks(originalTasks, testFile); err != nil {
+	if err := SaveTasks(originalTasks, 3, testFile); err != nil {
 		t.Fatalf("SaveTasks failed: %v", err)
 	}
 
 	// 2. Act: Load tasks
-	loadedTasks, err := LoadTasks(testFile)
+	loadedTasks, nextID, err := LoadTasks(testFile)
 	if err != nil {
 		t.Fatalf("LoadTasks failed: %v", err)
 	}
 
-	// 3. Assert Load & NextID calculation
+	// 3. Assert Load & NextID
 	if len(loadedTasks) != 2 {
 		t.Errorf("Expected 2 loaded tasks, got %d", len(loadedTasks))
 	}
+	if nextID != 3 {
+		t.Errorf("NextID failed. Expected 3, got %d", nextID)
+	}
+}
+
+func TestPersistence_PurgedIDNotReused(t *testing.T) {
+	testFile := t.TempDir() + "/tasks.json"
 
 	tm := NewTaskManager()
-	tm.Tasks = loadedTasks
-	if len(loadedTasks) > 0 {
-		tm.NextID = loadedTasks[len(loadedTasks)-1].ID + 1
+	tm.Add("Keep")
+	tm.Add("Purge")
+	tm.Delete(2)
+	tm.Purge(time.Now().Add(time.Minute))
+	if err := SaveTasks(tm.Tasks, tm.NextID, testFile); err != nil {
+		t.Fatalf("SaveTasks failed: %v", err)
 	}
-	if tm.NextID != 3 {
-		t.Errorf("NextID calculation failed. Expected 3, got %d", tm.NextID)
+
+	// Case 1: The counter survives the purge of the highest ID
+	tasks, nextID, err := LoadTasks(testFile)
+	if err != nil {
+		t.Fatalf("LoadTasks failed: %v", err)
+	}
+	reloaded := NewTaskManager()
+	reloaded.Tasks = tasks
+	reloaded.NextID = nextID
+	if task := reloaded.Add("New"); task.ID != 3 {
+		t.Errorf("Expected new task to get ID 3, got %d", task.ID)
+	}
+
+	// Case 2: Files holding just the task array still load, with the
+	// counter past the highest ID
+	os.WriteFile(testFile, []byte(`[{"id": 4, "description": "B"}, {"id": 2, "description": "A"}]`), 0644)
+	tasks, nextID, err = LoadTasks(testFile)
+	if err != nil {
+		t.Fatalf("LoadTasks failed on task array: %v", err)
+	}
+	if len(tasks) != 2 || nextID != 5 {
+		t.Errorf("Expected 2 tasks and NextID 5, got %d and %d", len(tasks), nextID)
 	}
 }
 
 func TestPersistence_LoadEmptyAndErrors(t *testing.T) {
 	// Case 1: Load non-existent file (First run scenario)
 	const nonExistentFile = "non_existent_test_data.json"
-	loadedTasks, err := LoadTasks(nonExistentFile)
+	loadedTasks, nextID, err := LoadTasks(nonExistentFile)
 	if err != nil {
 		t.Fatalf("LoadTasks failed on non-existent file: %v", err)
 	}
-	if len(loadedTasks) != 0 {
-		t.Error("LoadTasks failed to return empty slice for non-existent file.")
+	if len(loadedTasks) != 0 || nextID != 1 {
+		t.Error("LoadTasks failed to return empty slice and NextID 1 for non-existent file.")
 	}
 
 	// Case 2: Load corrupted JSON file
@@ -195,17 +301,63 @@ func TestPersistence_LoadEmptyAndErrors(t *testing.T) {
 	testFile := tempDir + "/corrupted.json"
 	os.WriteFile(testFile, []byte("invalid json: {"), 0644)
 
-	if _, err := LoadTasks(testFile); err == nil {
+	if _, _, err := LoadTasks(testFile); err == nil {
 		t.Error("Expected error when loading corrupted JSON, got nil.")
 	}
 
 	// Case 3: SaveTasks error (Permission/Invalid path)
 	tasks := []*Task{NewTask(1, "Test")}
-	if err := SaveTasks(tasks, "/invalid/path/tasks.json"); err == nil {
+	if err := SaveTasks(tasks, 2, "/invalid/path/tasks.json"); err == nil {
 		t.Error("Expected error when saving to invalid path, got nil.")
 	}
 }
 
+func TestPersistence_AtomicSaveAndBackupRecovery(t *testing.T) {
+	tempDir := t.TempDir()
+	testFile := tempDir + "/tasks.json"
+
+	// Two saves leave the first version in the backup file
+	SaveTasks([]*Task{NewTask(1, "A")}, 2, testFile)
+	SaveTasks([]*Task{NewTask(1, "A"), NewTask(2, "B")}, 3, testFile)
+
+	// No temp files should be left behind
+	entries, _ := os.ReadDir(tempDir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("Leftover temp file %s", e.Name())
+		}
+	}
+
+	// Corrupted main file falls back to the backup
+	os.WriteFile(testFile, []byte(`{"next_id": 3, "tasks": [{"id": 1, "desc`), 0644)
+	tasks, nextID, err := LoadTasks(testFile)
+	if err != nil {
+		t.Fatalf("Expected recovery from backup, got %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "A" || nextID != 2 {
+		t.Errorf("Expected backup contents, got %v and NextID %d", tasks, nextID)
+	}
+}
+
+func TestLockTasks(t *testing.T) {
+	testFile := t.TempDir() + "/tasks.json"
+
+	unlock, err := LockTasks(testFile)
+	if err != nil {
+		t.Fatalf("LockTasks failed: %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Errorf("unlock failed: %v", err)
+	}
+
+	// Lock can be taken again once released
+	unlock, err = LockTasks(testFile)
+	if err != nil {
+		t.Fatalf("LockTasks failed after release: %v", err)
+	}
+	unlock()
+}
+
 // --- CLI Handler Tests (Check Output and Error Handling) ---
 
 // Helper function to redirect stdout and capture output
@@ -236,6 +388,43 @@ func TestHandleAdd(t *testing.T) {
 	}
 }
 
+func TestHandleAdd_Options(t *testing.T) {
+	tm := NewTaskManager()
+
+	// Case 1: All options set
+	err := handleAdd(tm, "Quarterly report", "-priority", "high", "-due", "2026-11-01", "-tags", "work, finance", "-notes", "Ask Sam")
+	if err != nil {
+		t.Fatalf("handleAdd failed: %v", err)
+	}
+	task := tm.Tasks[0]
+	if task.Priority != "high" || task.DueAt == nil || len(task.Tags) != 2 || task.Notes != "Ask Sam" {
+		t.Errorf("Options not applied: %+v", task)
+	}
+	expected := "1. [ ] Quarterly report [high] (due 2026-11-01) #work #finance"
+	if str := task.String(); str != expected {
+		t.Errorf("String format error. Expected %q, got %q", expected, str)
+	}
+
+	// Case 2: Search also looks at tags and notes
+	if results := tm.Search("FINANCE"); len(results) != 1 {
+		t.Errorf("Search by tag failed, got %d results", len(results))
+	}
+	if results := tm.Search("sam"); len(results) != 1 {
+		t.Errorf("Search by notes failed, got %d results", len(results))
+	}
+
+	// Case 3: Invalid options leave the list untouched
+	if err := handleAdd(tm, "Bad", "-priority", "asap"); err == nil {
+		t.Error("Expected error for invalid priority")
+	}
+	if err := handleAdd(tm, "Bad", "-due", "next week"); err == nil {
+		t.Error("Expected error for invalid due date")
+	}
+	if len(tm.Tasks) != 1 {
+		t.Errorf("Invalid adds created tasks, got %d", len(tm.Tasks))
+	}
+}
+
 func TestHandleList(t *testing.T) {
 	tm := NewTaskManager()
 	tm.Add("List task 1")
@@ -255,6 +444,47 @@ func TestHandleList(t *testing.T) {
 	}
 }
 
+func TestHandleList_Filter(t *testing.T) {
+	tm := NewTaskManager()
+	handleAdd(tm, "Write quarterly report", "-tags", "work", "-due", "2026-10-30", "-priority", "high")
+	handleAdd(tm, "Quarterly report for home")
+	handleAdd(tm, "Old quarterly report", "-tags", "work")
+	tm.Complete(3)
+
+	// Case 1: Fields, comparisons and phrases combine
+	output := captureOutput(t, func() {
+		if err := handleList(tm, "-filter", `completed:false tag:work due<2026-11-01 "quarterly report"`); err != nil {
+			t.Errorf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(output, "1. [ ] Write quarterly report") || strings.Contains(output, "2.") || strings.Contains(output, "3.") {
+		t.Errorf("Expected only task 1, got %q", output)
+	}
+
+	// Case 2: OR, negation and priority ranks
+	output = captureOutput(t, func() { handleList(tm, "--filter", `priority>=high OR -tag:work`) })
+	if !strings.Contains(output, "1.") || !strings.Contains(output, "2.") || strings.Contains(output, "3.") {
+		t.Errorf("Expected tasks 1 and 2, got %q", output)
+	}
+
+	// Case 3: Parse errors name the position
+	err := handleList(tm, "-filter", `tag:work due<soon`)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) || syntaxErr.Pos != 13 {
+		t.Errorf("Expected syntax error at 13, got %v", err)
+	}
+	for input, pos := range map[string]int{`"open`: 0, `colour:red`: 0, `(tag:work`: 9, `completed:maybe`: 10} {
+		if _, err := ParseFilter(input); !errors.As(err, &syntaxErr) || syntaxErr.Pos != pos {
+			t.Errorf("ParseFilter(%q): expected error at %d, got %v", input, pos, err)
+		}
+	}
+	// Case 4: Multi-byte whitespace separates terms (and used to hang)
+	output = captureOutput(t, func() { handleList(tm, "-filter", "tag:work\u3000completed:false\u00a0") })
+	if !strings.Contains(output, "1.") || strings.Contains(output, "3.") {
+		t.Errorf("Expected only task 1, got %q", output)
+	}
+}
+
 func TestHandleCompleteAndDeleteErrors(t *testing.T) {
 	tm := NewTaskManager()
 	tm.Add("Task to test errors")
@@ -272,6 +502,83 @@ func TestHandleCompleteAndDeleteErrors(t *testing.T) {
 	}
 }
 
+func TestHandleTrashAndRestore(t *testing.T) {
+	tm := NewTaskManager()
+	tm.Add("Oops")
+
+	// Case 1: Empty trash
+	if output := captureOutput(t, func() { handleTrash(tm) }); !strings.Contains(output, "Trash is empty") {
+		t.Errorf("Expected empty trash message, got %q", output)
+	}
+
+	// Case 2: Deleted task shows up in the trash, restore takes it out
+	handleDelete(tm, "1")
+	if output := captureOutput(t, func() { handleTrash(tm) }); !strings.Contains(output, "1. [ ] Oops (deleted ") {
+		t.Errorf("Deleted task missing from trash output: %q", output)
+	}
+	if err := handleRestore(tm, "1"); err != nil || len(tm.List()) != 1 {
+		t.Errorf("handleRestore failed: %v", err)
+	}
+	if err := handleRestore(tm, "abc"); err == nil {
+		t.Error("handleRestore expected error on non-numeric ID")
+	}
+}
+
+func TestParseGlobalFlags(t *testing.T) {
+	// Case 1: --project before the command
+	project, rest, err := parseGlobalFlags([]string{"--project", "launch", "list", "-filter", "tag:work"})
+	if err != nil || project != "launch" || strings.Join(rest, " ") != "list -filter tag:work" {
+		t.Errorf("Got %q %q %v", project, rest, err)
+	}
+
+	// Case 2: No global flags
+	project, rest, _ = parseGlobalFlags([]string{"add", "Buy milk"})
+	if project != "" || len(rest) != 2 {
+		t.Errorf("Got %q %q", project, rest)
+	}
+
+	// Case 3: Unknown flag
+	if _, _, err := parseGlobalFlags([]string{"--verbose", "list"}); err == nil {
+		t.Error("Expected error for unknown flag")
+	}
+}
+
+func TestHandleAdd_Assignee(t *testing.T) {
+	tm := NewTaskManager()
+	tm.Project = "launch"
+	handleAdd(tm, "Write launch post", "-assignee", "sam")
+
+	if got := tm.Tasks[0].String(); got != "1. [ ] Write launch post +launch @sam" {
+		t.Errorf("Unexpected task line %q", got)
+	}
+
+	// Case 2: Personal tasks cannot be assigned, as in the API
+	tm.Project = ""
+	if err := handleAdd(tm, "Call mum", "-assignee", "sam"); err == nil || len(tm.Tasks) != 1 {
+		t.Errorf("Expected an error and no new task, got %v and %d tasks", err, len(tm.Tasks))
+	}
+}
+
+func TestPurgeDays(t *testing.T) {
+	cases := []struct {
+		value   string
+		days    int
+		wantErr bool
+	}{
+		{"", defaultPurgeDays, false},
+		{"7", 7, false},
+		{"0", 0, false},
+		{"-1", 0, true},
+		{"week", 0, true},
+	}
+	for _, c := range cases {
+		days, err := purgeDays(c.value)
+		if days != c.days || (err != nil) != c.wantErr {
+			t.Errorf("purgeDays(%q): expected %d (err %v), got %d (%v)", c.value, c.days, c.wantErr, days, err)
+		}
+	}
+}
+
 func TestHandleSearch(t *testing.T) {
 	tm := NewTaskManager()
 	tm.Add("Buy groceries")
@@ -1,12 +1,16 @@
 package main
 
 import (
-	"strings"
+	"fmt"
+	"time"
 )
 
 type TaskManager struct {
 	Tasks  []*Task
 	NextID int
+	// Project scopes every operation to one project's tasks and puts new
+	// tasks in it; empty means all tasks.
+	Project string
 }
 
 func NewTaskManager() *TaskManager {
@@ -18,41 +22,102 @@ func NewTaskManager() *TaskManager {
 
 func (tm *TaskManager) Add(description string) *Task {
 	task := NewTask(tm.NextID, description)
+	task.Project = tm.Project
 	tm.Tasks = append(tm.Tasks, task)
 	tm.NextID++
 	return task
 }
 
+// inScope reports whether task belongs to the selected project.
+func (tm *TaskManager) inScope(task *Task) bool {
+	return tm.Project == "" || task.Project == tm.Project
+}
+
+// get finds a task in scope that is not in the trash.
+func (tm *TaskManager) get(id int) (*Task, error) {
+	for _, task := range tm.Tasks {
+		if task.ID == id && !task.Deleted() && tm.inScope(task) {
+			return task, nil
+		}
+	}
+	return nil, TaskNotFoundError{ID: id}
+}
+
+// List returns every task in scope that is not in the trash.
 func (tm *TaskManager) List() []*Task {
-	return tm.Tasks
+	tasks := []*Task{}
+	for _, task := range tm.Tasks {
+		if !task.Deleted() && tm.inScope(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
 }
 
-func (tm *TaskManager) Complete(id int) error {
+// Trash returns the deleted tasks that have not been purged yet.
+func (tm *TaskManager) Trash() []*Task {
+	tasks := []*Task{}
 	for _, task := range tm.Tasks {
-		if task.ID == id {
-			task.Complete()
-			return nil
+		if task.Deleted() && tm.inScope(task) {
+			tasks = append(tasks, task)
 		}
 	}
-	return TaskNotFoundError{ID: id}
+	return tasks
 }
 
+func (tm *TaskManager) Complete(id int) error {
+	task, err := tm.get(id)
+	if err != nil {
+		return err
+	}
+	task.Complete()
+	return nil
+}
+
+// Delete moves the task to the trash; Purge removes it for good.
 func (tm *TaskManager) Delete(id int) error {
-	for idx, task := range tm.Tasks {
-		if task.ID == id {
-			// More efficient: avoid append allocation
-			copy(tm.Tasks[idx:], tm.Tasks[idx+1:])
-			tm.Tasks = tm.Tasks[:len(tm.Tasks)-1]
-			return nil
+	task, err := tm.get(id)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	task.DeletedAt = &now
+	return nil
+}
+
+// Restore takes a task back out of the trash.
+func (tm *TaskManager) Restore(id int) error {
+	for _, task := range tm.Tasks {
+		if task.ID != id || !tm.inScope(task) {
+			continue
+		}
+		if !task.Deleted() {
+			return fmt.Errorf("task %d is not in the trash", id)
 		}
+		task.DeletedAt = nil
+		return nil
 	}
 	return TaskNotFoundError{ID: id}
 }
 
+// Purge permanently removes tasks deleted before cutoff and returns how
+// many were removed.
+func (tm *TaskManager) Purge(cutoff time.Time) int {
+	kept := tm.Tasks[:0]
+	for _, task := range tm.Tasks {
+		if !task.Deleted() || !task.DeletedAt.Before(cutoff) || !tm.inScope(task) {
+			kept = append(kept, task)
+		}
+	}
+	purged := len(tm.Tasks) - len(kept)
+	tm.Tasks = kept
+	return purged
+}
+
 func (tm *TaskManager) Search(query string) []*Task {
 	results := []*Task{}
-	for _, task := range tm.Tasks {
-		if strings.Contains(strings.ToLower(task.Description), strings.ToLower(query)) {
+	for _, task := range tm.List() {
+		if task.matches(query) {
 			results = append(results, task)
 		}
 	}
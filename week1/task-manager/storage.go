@@ -1,35 +1,103 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"task-api/storage"
 )
 
 const filename = "tasks.json"
 
-func SaveTasks(tasks []*Task, filename string) error {
-	data, err := json.MarshalIndent(tasks, "", "  ")
+// backupName is where the last good copy of filename is kept.
+func backupName(filename string) string {
+	return filename + ".bak"
+}
+
+// taskFile is the layout of the tasks file. NextID is stored rather
+// than derived from the tasks so purging the task with the highest ID
+// never lets that ID be handed out again.
+type taskFile struct {
+	NextID int     `json:"next_id"`
+	Tasks  []*Task `json:"tasks"`
+}
+
+// SaveTasks atomically replaces filename, first copying the current
+// contents to the backup file if they still parse.
+func SaveTasks(tasks []*Task, nextID int, filename string) error {
+	data, err := json.MarshalIndent(taskFile{NextID: nextID, Tasks: tasks}, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filename, data, 0644)
+
+	if prev, err := os.ReadFile(filename); err == nil && len(prev) > 0 && json.Valid(prev) {
+		if err := storage.WriteFileAtomic(backupName(filename), prev, 0644); err != nil {
+			return err
+		}
+	}
+	return storage.WriteFileAtomic(filename, data, 0644)
 }
 
-func LoadTasks(filename string) ([]*Task, error) {
+// LoadTasks reads filename and returns its tasks and the next ID to
+// hand out, falling back to the backup copy when the main file is
+// corrupted.
+func LoadTasks(filename string) ([]*Task, int, error) {
+	file, err := readTasks(filename)
+	if err == nil {
+		return file.Tasks, file.NextID, nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+		return nil, 0, err
+	}
+
+	if _, statErr := os.Stat(backupName(filename)); statErr != nil {
+		return nil, 0, err
+	}
+	backup, backupErr := readTasks(backupName(filename))
+	if backupErr != nil {
+		return nil, 0, err
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s is corrupted, recovered tasks from %s\n", filename, backupName(filename))
+	return backup.Tasks, backup.NextID, nil
+}
+
+func readTasks(filename string) (*taskFile, error) {
+	file := &taskFile{Tasks: []*Task{}, NextID: 1}
 	data, err := os.ReadFile(filename)
 	if os.IsNotExist(err) {
-		return []*Task{}, nil // First run
+		return file, nil // First run
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
+	data = bytes.TrimSpace(data)
 	if len(data) == 0 {
-		return []*Task{}, nil
+		return file, nil
+	}
+
+	// Files written before NextID was stored hold just the task array.
+	if data[0] == '[' {
+		err = json.Unmarshal(data, &file.Tasks)
+	} else {
+		err = json.Unmarshal(data, file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Never hand out an ID that is still in the file, whatever the
+	// stored counter says.
+	for _, task := range file.Tasks {
+		if task.ID >= file.NextID {
+			file.NextID = task.ID + 1
+		}
 	}
-	
-	var tasks []*Task
-	err = json.Unmarshal(data, &tasks)
-	return tasks, err
+	return file, nil
 }
@@ -2,15 +2,38 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
+// priorities lists the accepted task priorities from lowest to highest.
+var priorities = []string{"low", "medium", "high", "urgent"}
+
+func validPriority(priority string) bool {
+	if priority == "" {
+		return true
+	}
+	for _, p := range priorities {
+		if p == priority {
+			return true
+		}
+	}
+	return false
+}
+
 type Task struct {
 	ID          int        `json:"id"`
 	Description string     `json:"description"`
 	Completed   bool       `json:"completed"`
 	CreatedAt   time.Time  `json:"created_at"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Priority    string     `json:"priority,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Project     string     `json:"project,omitempty"`
+	Assignee    string     `json:"assignee,omitempty"`
 }
 
 func NewTask(id int, description string) *Task {
@@ -28,12 +51,50 @@ func (t *Task) Complete() {
 	t.CompletedAt = &now
 }
 
+// Deleted reports whether the task is in the trash.
+func (t *Task) Deleted() bool {
+	return t.DeletedAt != nil
+}
+
 func (t *Task) String() string {
 	status := "[ ]"
 	if t.Completed {
 		status = "[✓]"
 	}
-	return fmt.Sprintf("%d. %s %s", t.ID, status, t.Description)
+	line := fmt.Sprintf("%d. %s %s", t.ID, status, t.Description)
+
+	if t.Priority != "" {
+		line += " [" + t.Priority + "]"
+	}
+	if t.DueAt != nil {
+		line += " (due " + t.DueAt.Format("2006-01-02") + ")"
+	}
+	for _, tag := range t.Tags {
+		line += " #" + tag
+	}
+	if t.Project != "" {
+		line += " +" + t.Project
+	}
+	if t.Assignee != "" {
+		line += " @" + t.Assignee
+	}
+	return line
+}
+
+// matches reports whether query appears in the description, notes or
+// any tag, ignoring case.
+func (t *Task) matches(query string) bool {
+	query = strings.ToLower(query)
+	if strings.Contains(strings.ToLower(t.Description), query) ||
+		strings.Contains(strings.ToLower(t.Notes), query) {
+		return true
+	}
+	for _, tag := range t.Tags {
+		if strings.Contains(strings.ToLower(tag), query) {
+			return true
+		}
+	}
+	return false
 }
 
 type TaskNotFoundError struct {
@@ -0,0 +1,3 @@
+module problem
+
+go 1.24.5
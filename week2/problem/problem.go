@@ -0,0 +1,181 @@
+// Package problem writes RFC 7807 application/problem+json error responses
+// and maps typed Go errors onto HTTP status codes. It is shared by the
+// week2 services so every API reports errors the same way.
+package problem
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const ContentType = "application/problem+json"
+
+// RequestIDHeader carries the ID that ties a problem to server logs.
+const RequestIDHeader = "X-Request-ID"
+
+// Problem is the RFC 7807 response body. RequestID and Errors are
+// extension members.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	Errors    any    `json:"errors,omitempty"`
+}
+
+// Error is an error that knows which HTTP status it maps to.
+type Error struct {
+	Status int
+	Detail string
+	Errors any   // optional extension member, e.g. field errors
+	Err    error // optional cause, kept out of the response
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return http.StatusText(e.Status)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is lets errors.Is match any *Error against the status sentinels below.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Detail == "" && t.Status == e.Status
+}
+
+// Sentinels for errors.Is checks, e.g. errors.Is(err, problem.ErrNotFound).
+var (
+	ErrBadRequest           = &Error{Status: http.StatusBadRequest}
+	ErrUnauthorized         = &Error{Status: http.StatusUnauthorized}
+	ErrForbidden            = &Error{Status: http.StatusForbidden}
+	ErrNotFound             = &Error{Status: http.StatusNotFound}
+	ErrMethodNotAllowed     = &Error{Status: http.StatusMethodNotAllowed}
+	ErrConflict             = &Error{Status: http.StatusConflict}
+	ErrPreconditionFailed   = &Error{Status: http.StatusPreconditionFailed}
+	ErrUnsupportedMediaType = &Error{Status: http.StatusUnsupportedMediaType}
+	ErrUnprocessable        = &Error{Status: http.StatusUnprocessableEntity}
+)
+
+func BadRequest(detail string) *Error {
+	return &Error{Status: http.StatusBadRequest, Detail: detail}
+}
+
+// Unauthorized reports missing or invalid credentials. Callers should
+// also set a WWW-Authenticate header.
+func Unauthorized(detail string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Detail: detail}
+}
+
+// Forbidden reports an authenticated caller lacking permission.
+func Forbidden(detail string) *Error {
+	return &Error{Status: http.StatusForbidden, Detail: detail}
+}
+
+func NotFound(detail string) *Error {
+	return &Error{Status: http.StatusNotFound, Detail: detail}
+}
+
+func MethodNotAllowed(detail string) *Error {
+	return &Error{Status: http.StatusMethodNotAllowed, Detail: detail}
+}
+
+func Conflict(detail string) *Error {
+	return &Error{Status: http.StatusConflict, Detail: detail}
+}
+
+func PreconditionFailed(detail string) *Error {
+	return &Error{Status: http.StatusPreconditionFailed, Detail: detail}
+}
+
+func UnsupportedMediaType(detail string) *Error {
+	return &Error{Status: http.StatusUnsupportedMediaType, Detail: detail}
+}
+
+// Unprocessable reports a well-formed request that failed validation;
+// errors is included in the response as the "errors" member.
+func Unprocessable(detail string, errors any) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Detail: detail, Errors: errors}
+}
+
+// StatusOf returns the status err maps to; unknown errors are 500s.
+func StatusOf(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Status
+	}
+	return http.StatusInternalServerError
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id, so that problems
+// written for the request reuse the ID its access log line carries.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// NewRequestID returns a random request ID.
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestID returns the request's ID: the one stored by WithRequestID,
+// else the client's X-Request-ID, else a new one.
+func RequestID(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok && id != "" {
+		return id
+	}
+	if id := r.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	return NewRequestID()
+}
+
+// New builds the Problem for err without the request members, e.g. to
+// report per-item failures inside a larger response. As in Write, the
+// detail of unknown (500) errors is withheld.
+func New(err error) Problem {
+	p := Problem{Type: "about:blank", Status: StatusOf(err)}
+	p.Title = http.StatusText(p.Status)
+
+	var e *Error
+	if errors.As(err, &e) {
+		p.Detail = e.Detail
+		p.Errors = e.Errors
+	}
+	return p
+}
+
+// Write sends err as a problem+json response. The detail of unknown
+// (500) errors is withheld so internals do not leak to clients.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	p := New(err)
+	p.Instance = r.URL.Path
+	p.RequestID = RequestID(r)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set(RequestIDHeader, p.RequestID)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Handler returns an http.Handler that always writes err, for use as a
+// router's NotFound or MethodNotAllowed handler.
+func Handler(err error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, err)
+	})
+}
@@ -0,0 +1,81 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusMapping(t *testing.T) {
+	cases := []struct {
+		err      error
+		sentinel error
+		status   int
+	}{
+		{BadRequest("bad json"), ErrBadRequest, http.StatusBadRequest},
+		{Unauthorized("missing credentials"), ErrUnauthorized, http.StatusUnauthorized},
+		{Forbidden("viewers cannot edit"), ErrForbidden, http.StatusForbidden},
+		{NotFound("task 9 not found"), ErrNotFound, http.StatusNotFound},
+		{MethodNotAllowed("use GET"), ErrMethodNotAllowed, http.StatusMethodNotAllowed},
+		{Conflict("already exists"), ErrConflict, http.StatusConflict},
+		{PreconditionFailed("stale ETag"), ErrPreconditionFailed, http.StatusPreconditionFailed},
+		{UnsupportedMediaType("want JSON"), ErrUnsupportedMediaType, http.StatusUnsupportedMediaType},
+		{Unprocessable("invalid", nil), ErrUnprocessable, http.StatusUnprocessableEntity},
+		{fmt.Errorf("loading: %w", NotFound("gone")), ErrNotFound, http.StatusNotFound},
+		{errors.New("disk on fire"), nil, http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		if got := StatusOf(c.err); got != c.status {
+			t.Errorf("StatusOf(%v): expected %d, got %d", c.err, c.status, got)
+		}
+		if c.sentinel != nil && !errors.Is(c.err, c.sentinel) {
+			t.Errorf("errors.Is(%v, sentinel %d) failed", c.err, c.status)
+		}
+	}
+
+	if errors.Is(NotFound("x"), ErrConflict) {
+		t.Error("NotFound should not match ErrConflict")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	// Case 1: Typed error with extension members and a client request ID
+	req := httptest.NewRequest("POST", "/tasks", nil)
+	req.Header.Set(RequestIDHeader, "abc123")
+	rec := httptest.NewRecorder()
+	Write(rec, req, Unprocessable("body failed validation", []string{"description is required"}))
+
+	if rec.Code != http.StatusUnprocessableEntity || rec.Header().Get("Content-Type") != ContentType {
+		t.Fatalf("Unexpected status/content type: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var p Problem
+	json.NewDecoder(rec.Body).Decode(&p)
+	if p.Type != "about:blank" || p.Title != "Unprocessable Entity" || p.Status != 422 ||
+		p.Detail != "body failed validation" || p.Instance != "/tasks" || p.RequestID != "abc123" || p.Errors == nil {
+		t.Errorf("Unexpected problem: %+v", p)
+	}
+
+	// Case 2: Unknown errors are 500s without leaking the detail
+	rec = httptest.NewRecorder()
+	Write(rec, httptest.NewRequest("GET", "/x", nil), errors.New("secret path /var/db"))
+	p = Problem{}
+	json.NewDecoder(rec.Body).Decode(&p)
+	if rec.Code != http.StatusInternalServerError || p.Detail != "" || p.RequestID == "" {
+		t.Errorf("Unexpected 500 problem: %d %+v", rec.Code, p)
+	}
+	if rec.Header().Get(RequestIDHeader) != p.RequestID {
+		t.Error("Generated request ID not echoed in the response header")
+	}
+
+	// Case 3: An ID stored in the context wins over the header
+	req = httptest.NewRequest("GET", "/x", nil)
+	req.Header.Set(RequestIDHeader, "from-client")
+	req = req.WithContext(WithRequestID(req.Context(), "from-context"))
+	if got := RequestID(req); got != "from-context" {
+		t.Errorf("Expected context request ID, got %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+// Package server runs an http.Server with production timeouts and shuts
+// it down gracefully. It is shared by the week2 services so they start,
+// stop and fail the same way.
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Config holds the http.Server settings.
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration // whole request, body included
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration // keep-alive connections
+	MaxHeaderBytes    int
+	// ShutdownTimeout bounds how long in-flight requests may take to
+	// finish once shutdown starts.
+	ShutdownTimeout time.Duration
+}
+
+// DefaultConfig returns conservative settings for a JSON API.
+func DefaultConfig() Config {
+	return Config{
+		Addr:              ":8080",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    1 << 20,
+		ShutdownTimeout:   20 * time.Second,
+	}
+}
+
+// New builds an http.Server for handler from cfg.
+func New(cfg Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// Run listens on cfg.Addr and serves handler until ctx is done, then
+// stops accepting connections and waits up to cfg.ShutdownTimeout for
+// in-flight requests. It returns an error if the address cannot be
+// bound, serving fails, or draining runs out of time; a clean shutdown
+// returns nil.
+func Run(ctx context.Context, cfg Config, handler http.Handler) error {
+	ln, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		return err
+	}
+	return Serve(ctx, ln, cfg, handler)
+}
+
+// Serve is Run on an existing listener, which it closes.
+func Serve(ctx context.Context, ln net.Listener, cfg Config, handler http.Handler) error {
+	srv := New(cfg, handler)
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	log.Printf("listening on %s", ln.Addr())
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, waiting up to %s for in-flight requests", cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	return ln
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})
+
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := DefaultConfig()
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, ln, cfg, handler) }()
+
+	// Case 1: A request in flight when shutdown starts still completes
+	got := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		got <- string(body)
+	}()
+	<-started
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+
+	// Case 2: New connections are refused while draining
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		t.Error("Expected new connections to be refused during shutdown")
+	}
+
+	close(release)
+	if body := <-got; body != "done" {
+		t.Errorf("Expected in-flight request to finish, got %q", body)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+}
+
+func TestServe_ShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+
+	ln := listen(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := DefaultConfig()
+	cfg.ShutdownTimeout = 50 * time.Millisecond
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, ln, cfg, handler) }()
+
+	go http.Get("http://" + ln.Addr().String())
+	<-started
+	cancel()
+	if err := <-served; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline error for a request that never finishes, got %v", err)
+	}
+}
+
+func TestRun_StartupFailure(t *testing.T) {
+	ln := listen(t)
+	defer ln.Close()
+
+	cfg := DefaultConfig()
+	cfg.Addr = ln.Addr().String()
+	if err := Run(context.Background(), cfg, http.NotFoundHandler()); err == nil {
+		t.Error("Expected an error when the address is already in use")
+	}
+}
+
+func TestNew(t *testing.T) {
+	cfg := DefaultConfig()
+	srv := New(cfg, http.NotFoundHandler())
+	if srv.ReadHeaderTimeout != cfg.ReadHeaderTimeout || srv.WriteTimeout != cfg.WriteTimeout ||
+		srv.IdleTimeout != cfg.IdleTimeout || srv.MaxHeaderBytes != cfg.MaxHeaderBytes || srv.Addr != ":8080" {
+		t.Errorf("Server does not carry the config: %+v", srv)
+	}
+}
@@ -1,3 +1,13 @@
 module simple-api
 
 go 1.24.5
+
+require (
+	problem v0.0.0
+	server v0.0.0
+)
+
+replace (
+	problem => ../problem
+	server => ../server
+)
@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
+	"problem"
 )
 
 type ResponseStatus struct {
@@ -13,10 +15,6 @@ type MessageResponse struct {
 	Message string `json:"message"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 func jsonHandler(w http.ResponseWriter, data any) {
 	w.Header().Set("Content-type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
@@ -24,22 +22,25 @@ func jsonHandler(w http.ResponseWriter, data any) {
 	}
 }
 
-func jsonError(w http.ResponseWriter, message string, code int) {
-	w.Header().Set("Content-type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+// allowMethod answers 405 with an Allow header when r uses another method.
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	problem.Write(w, r, problem.MethodNotAllowed("use "+method))
+	return false
 }
 
 func health(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		jsonError(w, "Method not allowed", http.StatusInternalServerError)
+	if !allowMethod(w, r, http.MethodGet) {
+		return
 	}
 	jsonHandler(w, ResponseStatus{Status: "ok"})
 }
 
 func hello(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		jsonError(w, "Method not allowed", http.StatusInternalServerError)
+	if !allowMethod(w, r, http.MethodGet) {
 		return
 	}
 	name := "World!"
@@ -54,15 +55,21 @@ func hello(w http.ResponseWriter, r *http.Request) {
 }
 
 func echo(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		jsonError(w, "Method not allowed", http.StatusInternalServerError)
+	if !allowMethod(w, r, http.MethodPost) {
 		return
 	}
 
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mediaType, _, err := mime.ParseMediaType(ct); err != nil || mediaType != "application/json" {
+			problem.Write(w, r, problem.UnsupportedMediaType("Content-Type must be application/json"))
+			return
+		}
+	}
+
 	defer r.Body.Close()
 	data := make(map[string]any)
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		jsonError(w, "Invalid Json", http.StatusInternalServerError)
+		problem.Write(w, r, problem.BadRequest("Invalid JSON: "+err.Error()))
 		return
 	}
 
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"problem"
+	"strings"
+	"testing"
+)
+
+func TestHandlers(t *testing.T) {
+	cases := []struct {
+		name        string
+		handler     http.HandlerFunc
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"health ok", health, "GET", "/health", "", "", http.StatusOK},
+		{"health wrong method", health, "POST", "/health", "", "", http.StatusMethodNotAllowed},
+		{"hello ok", hello, "GET", "/hello/Go", "", "", http.StatusOK},
+		{"hello wrong method", hello, "DELETE", "/hello/Go", "", "", http.StatusMethodNotAllowed},
+		{"echo ok", echo, "POST", "/echo", "application/json", `{"a":1}`, http.StatusOK},
+		{"echo wrong method", echo, "GET", "/echo", "", "", http.StatusMethodNotAllowed},
+		{"echo invalid json", echo, "POST", "/echo", "application/json", `{"a":`, http.StatusBadRequest},
+		{"echo wrong media type", echo, "POST", "/echo", "text/plain", `{"a":1}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			if c.contentType != "" {
+				req.Header.Set("Content-Type", c.contentType)
+			}
+			rec := httptest.NewRecorder()
+			c.handler(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("Expected %d, got %d: %s", c.status, rec.Code, rec.Body)
+			}
+			if c.status < 400 {
+				return
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Expected problem+json, got %q", ct)
+			}
+			var p problem.Problem
+			json.NewDecoder(rec.Body).Decode(&p)
+			if p.Status != c.status || p.Instance != c.target {
+				t.Errorf("Unexpected problem body: %+v", p)
+			}
+			if c.status == http.StatusMethodNotAllowed && rec.Header().Get("Allow") == "" {
+				t.Error("405 without an Allow header")
+			}
+		})
+	}
+}
@@ -1,17 +1,32 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"server"
+	"syscall"
 )
 
 func main() {
-	http.HandleFunc("/health", health)
-	http.HandleFunc("/hello/", hello) // Note the trailing slash
-	http.HandleFunc("/echo", echo)
+	cfg := server.DefaultConfig()
+	flag.StringVar(&cfg.Addr, "addr", cfg.Addr, "address to listen on")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "how long to wait for in-flight requests on shutdown")
+	flag.Parse()
 
-	fmt.Println("Server starting on :8080...")
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		fmt.Printf("Server failed to start: %v\n", err)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", health)
+	mux.HandleFunc("/hello/", hello) // Note the trailing slash
+	mux.HandleFunc("/echo", echo)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := server.Run(ctx, cfg, mux); err != nil {
+		log.Printf("simple-api: %v", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,242 @@
+// Package config assembles task-api's settings from built-in defaults, a
+// YAML, TOML or JSON config file, TASKAPI_* environment variables and
+// command-line flags, each layer overriding the one before.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"server"
+	"strings"
+	"task-api/storage"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration written as "15s" or "2m" in files,
+// environment variables and flags.
+type Duration time.Duration
+
+func (d Duration) String() string { return time.Duration(d).String() }
+
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) { return []byte(d.String()), nil }
+
+func (d *Duration) UnmarshalText(text []byte) error { return d.Set(string(text)) }
+
+// List is a string list written as a comma-separated value in
+// environment variables and flags.
+type List []string
+
+func (l List) String() string { return strings.Join(l, ",") }
+
+func (l *List) Set(s string) error {
+	*l = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// Storage selects and locates the task and project stores.
+type Storage struct {
+	// Kind is json, sqlite or wal.
+	Kind string `json:"kind" yaml:"kind" toml:"kind"`
+	// DataDir is where relative file paths below are resolved.
+	DataDir   string   `json:"data_dir" yaml:"data_dir" toml:"data_dir"`
+	TasksFile string   `json:"tasks_file" yaml:"tasks_file" toml:"tasks_file"`
+	DB        string   `json:"db" yaml:"db" toml:"db"`
+	WAL       string   `json:"wal" yaml:"wal" toml:"wal"`
+	Projects  string   `json:"projects" yaml:"projects" toml:"projects"`
+	Flush     Duration `json:"flush" yaml:"flush" toml:"flush"`
+	PurgeDays int      `json:"purge_days" yaml:"purge_days" toml:"purge_days"`
+}
+
+// CORS is the cross-origin policy; see middleware.CORSConfig.
+type CORS struct {
+	Origins     List     `json:"origins" yaml:"origins" toml:"origins"`
+	Credentials bool     `json:"credentials" yaml:"credentials" toml:"credentials"`
+	MaxAge      Duration `json:"max_age" yaml:"max_age" toml:"max_age"`
+}
+
+// Server holds the HTTP server limits; see server.Config.
+type Server struct {
+	ReadTimeout       Duration `json:"read_timeout" yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout" yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      Duration `json:"write_timeout" yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       Duration `json:"idle_timeout" yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxHeaderBytes    int      `json:"max_header_bytes" yaml:"max_header_bytes" toml:"max_header_bytes"`
+	ShutdownTimeout   Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+// Log selects how log records are written to stderr.
+type Log struct {
+	// Format is json or text.
+	Format string     `json:"format" yaml:"format" toml:"format"`
+	Level  slog.Level `json:"level" yaml:"level" toml:"level"`
+}
+
+// Tracing selects where spans are exported.
+type Tracing struct {
+	// Exporter is none, stdout or file.
+	Exporter string `json:"exporter" yaml:"exporter" toml:"exporter"`
+	// File receives spans, one JSON object per line, with exporter file.
+	File string `json:"file" yaml:"file" toml:"file"`
+}
+
+// Config is every task-api setting.
+type Config struct {
+	Addr string `json:"addr" yaml:"addr" toml:"addr"`
+	// Auth is the auth config file (see middleware.LoadAuthConfig); empty
+	// disables auth.
+	Auth string `json:"auth" yaml:"auth" toml:"auth"`
+	// MetricsPath serves Prometheus metrics, outside auth; empty turns
+	// the endpoint off.
+	MetricsPath string  `json:"metrics_path" yaml:"metrics_path" toml:"metrics_path"`
+	Storage     Storage `json:"storage" yaml:"storage" toml:"storage"`
+	CORS        CORS    `json:"cors" yaml:"cors" toml:"cors"`
+	Server      Server  `json:"server" yaml:"server" toml:"server"`
+	Log         Log     `json:"log" yaml:"log" toml:"log"`
+	Tracing     Tracing `json:"tracing" yaml:"tracing" toml:"tracing"`
+
+	// PrintConfig asks main to print the result and exit.
+	PrintConfig bool `json:"-" yaml:"-" toml:"-"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() *Config {
+	srv := server.DefaultConfig()
+	return &Config{
+		Addr:        srv.Addr,
+		MetricsPath: "/metrics",
+		Storage: Storage{
+			Kind:      "json",
+			DataDir:   ".",
+			TasksFile: storage.Filename,
+			DB:        "tasks.db",
+			WAL:       "tasks.log",
+			Projects:  "projects.json",
+			Flush:     Duration(time.Second),
+			PurgeDays: 30,
+		},
+		CORS: CORS{
+			Origins: List{"*"},
+			MaxAge:  Duration(10 * time.Minute),
+		},
+		Server: Server{
+			ReadTimeout:       Duration(srv.ReadTimeout),
+			ReadHeaderTimeout: Duration(srv.ReadHeaderTimeout),
+			WriteTimeout:      Duration(srv.WriteTimeout),
+			IdleTimeout:       Duration(srv.IdleTimeout),
+			MaxHeaderBytes:    srv.MaxHeaderBytes,
+			ShutdownTimeout:   Duration(srv.ShutdownTimeout),
+		},
+		Log:     Log{Format: "json", Level: slog.LevelInfo},
+		Tracing: Tracing{Exporter: "none", File: "traces.jsonl"},
+	}
+}
+
+// Path resolves a storage file name against DataDir.
+func (c *Config) Path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.Storage.DataDir, name)
+}
+
+// ServerConfig converts the server settings for server.Run.
+func (c *Config) ServerConfig() server.Config {
+	return server.Config{
+		Addr:              c.Addr,
+		ReadTimeout:       time.Duration(c.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(c.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(c.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(c.Server.IdleTimeout),
+		MaxHeaderBytes:    c.Server.MaxHeaderBytes,
+		ShutdownTimeout:   time.Duration(c.Server.ShutdownTimeout),
+	}
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, port, err := net.SplitHostPort(c.Addr)
+	check(err == nil && port != "", "addr %q must be host:port or :port", c.Addr)
+
+	check(c.MetricsPath == "" || strings.HasPrefix(c.MetricsPath, "/"), "metrics_path %q must start with /", c.MetricsPath)
+
+	switch c.Storage.Kind {
+	case "json", "sqlite", "wal":
+	default:
+		check(false, "storage.kind %q must be json, sqlite or wal", c.Storage.Kind)
+	}
+	if info, err := os.Stat(c.Storage.DataDir); err != nil || !info.IsDir() {
+		check(false, "storage.data_dir %q is not a directory", c.Storage.DataDir)
+	}
+	check(c.Storage.TasksFile != "", "storage.tasks_file must be set")
+	check(c.Storage.DB != "", "storage.db must be set")
+	check(c.Storage.WAL != "", "storage.wal must be set")
+	check(c.Storage.Projects != "", "storage.projects must be set")
+	check(c.Storage.Flush > 0, "storage.flush must be positive")
+	check(c.Storage.PurgeDays >= 0, "storage.purge_days must not be negative")
+
+	check(len(c.CORS.Origins) > 0, "cors.origins must list at least one origin")
+	for _, origin := range c.CORS.Origins {
+		check(!(origin == "*" && c.CORS.Credentials), "cors.credentials needs explicit cors.origins, not *")
+	}
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+
+	check(c.Server.ReadTimeout > 0, "server.read_timeout must be positive")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read_header_timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "server.max_header_bytes must be positive")
+
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format %q must be json or text", c.Log.Format)
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "file":
+		check(c.Tracing.File != "", "tracing.file must be set for the file exporter")
+	default:
+		check(false, "tracing.exporter %q must be none, stdout or file", c.Tracing.Exporter)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid config: " + strings.Join(problems, "; "))
+}
+
+// Print writes the configuration as YAML, the same shape a config file
+// takes.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
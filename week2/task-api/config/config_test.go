@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env is a fixed environment for Load.
+func env(pairs ...string) func(string) (string, bool) {
+	vars := map[string]string{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		vars[pairs[i]] = pairs[i+1]
+	}
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"task-api.yaml": `
+addr: ":9000"
+storage:
+  kind: sqlite
+  data_dir: ` + dir + `
+  flush: 5s
+cors:
+  origins: [https://app.example.com]
+  max_age: 1h
+server:
+  write_timeout: 1m
+`,
+		"task-api.toml": `
+addr = ":9000"
+
+[storage]
+kind = "sqlite"
+data_dir = "` + dir + `"
+flush = "5s"
+
+[cors]
+origins = ["https://app.example.com"]
+max_age = "1h"
+
+[server]
+write_timeout = "1m"
+`,
+		"task-api.json": `{
+  "addr": ":9000",
+  "storage": {"kind": "sqlite", "data_dir": "` + dir + `", "flush": "5s"},
+  "cors": {"origins": ["https://app.example.com"], "max_age": "1h"},
+  "server": {"write_timeout": "1m"}
+}`,
+	}
+
+	for name, content := range files {
+		path := writeFile(t, name, content)
+
+		// Case 1: The file overrides defaults and leaves the rest alone
+		cfg, err := Load([]string{"-config", path}, env())
+		if err != nil {
+			t.Fatalf("%s: Load failed: %v", name, err)
+		}
+		if cfg.Addr != ":9000" || cfg.Storage.Kind != "sqlite" || cfg.Storage.Flush != Duration(5*time.Second) ||
+			cfg.CORS.MaxAge != Duration(time.Hour) || cfg.Server.WriteTimeout != Duration(time.Minute) {
+			t.Errorf("%s: file settings not applied: %+v", name, cfg)
+		}
+		if cfg.Storage.DB != "tasks.db" || cfg.Storage.PurgeDays != 30 || cfg.Server.IdleTimeout != Duration(2*time.Minute) {
+			t.Errorf("%s: defaults lost: %+v", name, cfg)
+		}
+		if got := cfg.Path(cfg.Storage.DB); got != filepath.Join(dir, "tasks.db") {
+			t.Errorf("%s: expected db under data_dir, got %q", name, got)
+		}
+
+		// Case 2: Environment beats the file, flags beat both
+		cfg, err = Load([]string{"-addr", ":7000", "-cors-origins", "https://a.example.com, https://b.example.com"},
+			env("TASKAPI_CONFIG", path, "TASKAPI_ADDR", ":8000", "TASKAPI_FLUSH", "2s", "TASKAPI_PURGE_DAYS", "7", "TASKAPI_LOG_LEVEL", "debug"))
+		if err != nil {
+			t.Fatalf("%s: Load failed: %v", name, err)
+		}
+		if cfg.Addr != ":7000" || cfg.Storage.Flush != Duration(2*time.Second) || cfg.Storage.PurgeDays != 7 || cfg.Storage.Kind != "sqlite" {
+			t.Errorf("%s: wrong precedence: %+v", name, cfg)
+		}
+		if cfg.Log.Level != slog.LevelDebug {
+			t.Errorf("%s: expected debug level from the environment, got %v", name, cfg.Log.Level)
+		}
+		if got := cfg.CORS.Origins.String(); got != "https://a.example.com,https://b.example.com" {
+			t.Errorf("%s: expected origins from the flag, got %q", name, got)
+		}
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	// Case 1: Unknown keys in a file are rejected
+	for name, content := range map[string]string{
+		"typo.yaml": "adr: \":9000\"\n",
+		"typo.toml": "adr = \":9000\"\n",
+		"typo.json": `{"adr": ":9000"}`,
+		"typo.ini":  "addr=:9000\n",
+	} {
+		if _, err := Load([]string{"-config", writeFile(t, name, content)}, env()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// Case 2: A bad environment value names the variable
+	_, err := Load(nil, env("TASKAPI_FLUSH", "soon"))
+	if err == nil || !strings.Contains(err.Error(), "TASKAPI_FLUSH") {
+		t.Errorf("Expected TASKAPI_FLUSH error, got %v", err)
+	}
+
+	// Case 3: Validation reports every problem at once
+	_, err = Load([]string{"-addr", "8080", "-storage", "mongo", "-flush", "0s", "-cors-credentials"}, env())
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, want := range []string{"addr", "storage.kind", "storage.flush", "cors.credentials"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q in %v", want, err)
+		}
+	}
+	_, err = Load([]string{"-data-dir", filepath.Join(t.TempDir(), "missing")}, env())
+	if err == nil || !strings.Contains(err.Error(), "storage.data_dir") {
+		t.Errorf("Expected data_dir error, got %v", err)
+	}
+
+	// Case 4: -h is reported as flag.ErrHelp
+	if _, err := Load([]string{"-h"}, env()); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("Expected flag.ErrHelp, got %v", err)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	cfg, err := Load([]string{"-print-config", "-addr", ":9000"}, env())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.PrintConfig {
+		t.Error("Expected PrintConfig to be set")
+	}
+
+	// The printed config loads back to the same settings
+	var buf bytes.Buffer
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatalf("Print failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `addr: :9000`) || !strings.Contains(buf.String(), "flush: 1s") {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+	again, err := Load([]string{"-config", writeFile(t, "printed.yaml", buf.String())}, env())
+	if err != nil {
+		t.Fatalf("Loading printed config failed: %v", err)
+	}
+	again.PrintConfig = true
+	if again.Addr != cfg.Addr || again.Storage != cfg.Storage || again.Server != cfg.Server || again.Log != cfg.Log || again.CORS.Origins.String() != cfg.CORS.Origins.String() {
+		t.Errorf("Round trip changed the config:\n%+v\n%+v", again, cfg)
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts every environment variable Load reads. The rest of
+// the name is the flag name in upper case with - replaced by _, so
+// -cors-origins is TASKAPI_CORS_ORIGINS.
+const EnvPrefix = "TASKAPI_"
+
+// envName is the environment variable that sets a flag.
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// flags registers one flag per setting, bound to c's fields.
+func (c *Config) flags(fs *flag.FlagSet, configPath *string) {
+	fs.StringVar(configPath, "config", "", "YAML, TOML or JSON config file")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the effective configuration and exit")
+
+	fs.StringVar(&c.Addr, "addr", c.Addr, "address to listen on")
+	fs.StringVar(&c.Auth, "auth", c.Auth, "auth config file with API keys and JWT settings (empty disables auth)")
+	fs.StringVar(&c.MetricsPath, "metrics-path", c.MetricsPath, "path serving Prometheus metrics without auth (empty disables it)")
+
+	fs.StringVar(&c.Storage.Kind, "storage", c.Storage.Kind, "storage backend: json, sqlite or wal")
+	fs.StringVar(&c.Storage.DataDir, "data-dir", c.Storage.DataDir, "directory relative storage paths are resolved against")
+	fs.StringVar(&c.Storage.TasksFile, "tasks-file", c.Storage.TasksFile, "task file (with -storage=json)")
+	fs.StringVar(&c.Storage.DB, "db", c.Storage.DB, "SQLite database path (with -storage=sqlite)")
+	fs.StringVar(&c.Storage.WAL, "wal", c.Storage.WAL, "event log path (with -storage=wal)")
+	fs.StringVar(&c.Storage.Projects, "projects", c.Storage.Projects, "JSON file holding projects and their members (with -storage=json; sqlite and wal import it once)")
+	fs.Var(&c.Storage.Flush, "flush", "how often pending writes are persisted")
+	fs.IntVar(&c.Storage.PurgeDays, "purge-days", c.Storage.PurgeDays, "permanently remove deleted tasks after this many days (0 keeps them)")
+
+	fs.Var(&c.CORS.Origins, "cors-origins", "comma-separated origins or patterns (https://*.example.com) allowed to call the API")
+	fs.BoolVar(&c.CORS.Credentials, "cors-credentials", c.CORS.Credentials, "allow browsers to send credentials (needs explicit -cors-origins)")
+	fs.Var(&c.CORS.MaxAge, "cors-max-age", "how long browsers may cache a preflight response")
+
+	fs.Var(&c.Server.ReadTimeout, "read-timeout", "maximum time to read a request, body included")
+	fs.Var(&c.Server.ReadHeaderTimeout, "read-header-timeout", "maximum time to read request headers")
+	fs.Var(&c.Server.WriteTimeout, "write-timeout", "maximum time to write a response")
+	fs.Var(&c.Server.IdleTimeout, "idle-timeout", "how long keep-alive connections may sit idle")
+	fs.IntVar(&c.Server.MaxHeaderBytes, "max-header-bytes", c.Server.MaxHeaderBytes, "maximum size of request headers")
+	fs.Var(&c.Server.ShutdownTimeout, "shutdown-timeout", "how long to wait for in-flight requests on shutdown")
+
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "log output: json or text")
+	fs.TextVar(&c.Log.Level, "log-level", c.Log.Level, "lowest level logged: debug, info, warn or error")
+
+	fs.StringVar(&c.Tracing.Exporter, "trace-exporter", c.Tracing.Exporter, "where spans go: none, stdout or file")
+	fs.StringVar(&c.Tracing.File, "trace-file", c.Tracing.File, "span file, one JSON object per line (with -trace-exporter=file)")
+}
+
+// Load builds the configuration for args (without the program name).
+// Later layers win:
+//
+//  1. built-in defaults
+//  2. the config file named by -config or TASKAPI_CONFIG
+//  3. TASKAPI_* environment variables
+//  4. command-line flags
+//
+// lookupEnv is normally os.LookupEnv. The result is validated.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	c := Default()
+	var configPath string
+	fs := flag.NewFlagSet("task-api", flag.ContinueOnError)
+	c.flags(fs, &configPath)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of task-api (every flag can also be set as %s<FLAG>):\n", EnvPrefix)
+		fs.PrintDefaults()
+	}
+
+	// Flags are parsed first to find -config, and replayed at the end so
+	// they override the file and the environment.
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = f.Value.String() })
+
+	if configPath == "" {
+		configPath, _ = lookupEnv(envName("config"))
+	}
+	if configPath != "" {
+		if err := c.loadFile(configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" || envErr != nil {
+			return
+		}
+		if value, ok := lookupEnv(envName(f.Name)); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				envErr = fmt.Errorf("%s: %w", envName(f.Name), err)
+			}
+		}
+	})
+	if envErr != nil {
+		return nil, envErr
+	}
+
+	names := make([]string, 0, len(given))
+	for name := range given {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fs.Set(name, given[name]) // parsed once already, cannot fail
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// loadFile overlays the settings in path, picking the format from its
+// extension. Unknown keys are errors so typos do not go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && err != io.EOF {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: unknown config format (want .yaml, .yml, .toml or .json)", path)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"task-api/models"
+	"task-api/search"
+	"time"
+)
+
+// Expr is a parsed filter.
+type Expr interface {
+	Match(task *models.Task) bool
+	String() string
+}
+
+// All matches every task; it is what an empty filter parses to.
+type All struct{}
+
+func (All) Match(*models.Task) bool { return true }
+func (All) String() string          { return "*" }
+
+type And struct{ Left, Right Expr }
+
+func (e And) Match(task *models.Task) bool { return e.Left.Match(task) && e.Right.Match(task) }
+func (e And) String() string               { return "(" + e.Left.String() + " AND " + e.Right.String() + ")" }
+
+type Or struct{ Left, Right Expr }
+
+func (e Or) Match(task *models.Task) bool { return e.Left.Match(task) || e.Right.Match(task) }
+func (e Or) String() string               { return "(" + e.Left.String() + " OR " + e.Right.String() + ")" }
+
+type Not struct{ X Expr }
+
+func (e Not) Match(task *models.Task) bool { return !e.X.Match(task) }
+func (e Not) String() string               { return "NOT " + e.X.String() }
+
+// Text matches when Value appears in the description, notes or any tag,
+// ignoring case.
+type Text struct{ Value string }
+
+func (e Text) Match(task *models.Task) bool {
+	if contains(task.Description, e.Value) || contains(task.Notes, e.Value) {
+		return true
+	}
+	for _, tag := range task.Tags {
+		if contains(tag, e.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Text) String() string { return strconv.Quote(e.Value) }
+
+// contains is a case-insensitive substring test using the same Unicode
+// normalization as search.
+func contains(text, value string) bool {
+	return strings.Contains(search.Normalize(text), search.Normalize(value))
+}
+
+// Compare tests one task field against a value. Ordered fields compare
+// with <, <=, > and >=; ":" (or "=") means equal. Dates cover a range
+// [lo, hi): a whole day for YYYY-MM-DD, a single instant otherwise.
+type Compare struct {
+	Field string
+	Op    string
+	Value string
+
+	boolean bool
+	number  int
+	lo, hi  time.Time
+	none    bool // due:none
+}
+
+func (e Compare) String() string { return e.Field + e.Op + strconv.Quote(e.Value) }
+
+// fieldOps lists the operators each field accepts.
+var fieldOps = map[string]string{
+	"completed":   ":",
+	"tag":         ":",
+	"description": ":",
+	"notes":       ":",
+	"priority":    ":<>",
+	"due":         ":<>",
+	"created":     ":<>",
+	"id":          ":<>",
+}
+
+func newCompare(field, op, value token, loc *time.Location) (Expr, error) {
+	allowed, ok := fieldOps[field.text]
+	if !ok {
+		return nil, &SyntaxError{Pos: field.pos, Msg: fmt.Sprintf("unknown field %q", field.text)}
+	}
+	c := Compare{Field: field.text, Op: op.text, Value: value.text}
+	if c.Op == "=" {
+		c.Op = ":"
+	}
+	if !strings.ContainsRune(allowed, rune(c.Op[0])) {
+		return nil, &SyntaxError{Pos: op.pos, Msg: fmt.Sprintf("%s does not support %s", c.Field, c.Op)}
+	}
+
+	bad := func(want string) error {
+		return &SyntaxError{Pos: value.pos, Msg: fmt.Sprintf("%s needs %s, got %q", c.Field, want, c.Value)}
+	}
+	switch c.Field {
+	case "completed":
+		b, err := strconv.ParseBool(c.Value)
+		if err != nil {
+			return nil, bad("true or false")
+		}
+		c.boolean = b
+	case "priority":
+		p := models.Priority(strings.ToLower(c.Value))
+		if p.Rank() == 0 {
+			return nil, bad("low, medium, high or urgent")
+		}
+		c.number = p.Rank()
+	case "id":
+		n, err := strconv.Atoi(c.Value)
+		if err != nil {
+			return nil, bad("a number")
+		}
+		c.number = n
+	case "due", "created":
+		if c.Field == "due" && c.Op == ":" && c.Value == "none" {
+			c.none = true
+			break
+		}
+		lo, hi, err := parseDateRange(c.Value, loc)
+		if err != nil {
+			return nil, bad("a date (YYYY-MM-DD) or RFC 3339 time")
+		}
+		c.lo, c.hi = lo, hi
+	}
+	return c, nil
+}
+
+func parseDateRange(value string, loc *time.Location) (time.Time, time.Time, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return day, day.AddDate(0, 0, 1), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, t.Add(time.Nanosecond), err
+}
+
+func (e Compare) Match(task *models.Task) bool {
+	switch e.Field {
+	case "completed":
+		return task.Completed == e.boolean
+	case "tag":
+		return task.HasTag(e.Value)
+	case "description":
+		return contains(task.Description, e.Value)
+	case "notes":
+		return contains(task.Notes, e.Value)
+	case "priority":
+		return compareInts(task.Priority.Rank(), e.Op, e.number)
+	case "id":
+		return compareInts(task.ID, e.Op, e.number)
+	case "due":
+		if e.none {
+			return task.DueAt == nil
+		}
+		return task.DueAt != nil && e.inRange(*task.DueAt)
+	case "created":
+		return e.inRange(task.CreatedAt)
+	}
+	return false
+}
+
+func compareInts(have int, op string, want int) bool {
+	switch op {
+	case "<":
+		return have < want
+	case "<=":
+		return have <= want
+	case ">":
+		return have > want
+	case ">=":
+		return have >= want
+	}
+	return have == want
+}
+
+// inRange applies the operator to t and the value's [lo, hi) range.
+func (e Compare) inRange(t time.Time) bool {
+	switch e.Op {
+	case "<":
+		return t.Before(e.lo)
+	case "<=":
+		return t.Before(e.hi)
+	case ">":
+		return !t.Before(e.hi)
+	case ">=":
+		return !t.Before(e.lo)
+	}
+	return !t.Before(e.lo) && t.Before(e.hi)
+}
@@ -0,0 +1,106 @@
+package filter
+
+import (
+	"errors"
+	"task-api/models"
+	"testing"
+	"time"
+)
+
+func TestParse_Tree(t *testing.T) {
+	cases := map[string]string{
+		``:                                   `*`,
+		`completed:false tag:work`:           `(completed:"false" AND tag:"work")`,
+		`a b OR c`:                           `(("a" AND "b") OR "c")`,
+		`a (b OR c)`:                         `("a" AND ("b" OR "c"))`,
+		`-tag:home NOT "e-mail"`:             `(NOT tag:"home" AND NOT "e-mail")`,
+		`due<2026-11-01 AND priority>=high`:  `(due<"2026-11-01" AND priority>="high")`,
+		`tag:"needs review" description=buy`: `(tag:"needs review" AND description:"buy")`,
+		// Multi-byte whitespace separates terms (and used to hang the lexer)
+		"\u3000":             `*`,
+		"tag:work\u00a0":     `tag:"work"`,
+		"a\u3000b\u2003OR c": `(("a" AND "b") OR "c")`,
+	}
+	for input, want := range cases {
+		expr, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", input, err)
+			continue
+		}
+		if got := expr.String(); got != want {
+			t.Errorf("Parse(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := map[string]int{
+		`"quarterly report`:     0,
+		`colour:red`:            0,
+		`completed:maybe`:       10,
+		`tag<work`:              3,
+		`due<11/01/2026`:        4,
+		`priority:critical`:     9,
+		`tag:`:                  4,
+		`(tag:work`:             9,
+		`tag:work)`:             8,
+		`OR tag:work`:           0,
+		`tag:work OR`:           11,
+		`completed:false id:x1`: 19,
+	}
+	for input, pos := range cases {
+		_, err := Parse(input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Pos != pos {
+			t.Errorf("Parse(%q): expected error at %d, got %v", input, pos, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	due := time.Date(2026, 10, 31, 17, 0, 0, 0, time.UTC)
+	report := models.NewTask(1, "Write quarterly report")
+	report.Priority, report.DueAt, report.Tags = models.PriorityHigh, &due, []string{"work"}
+	groceries := models.NewTask(2, "Buy groceries")
+	groceries.Notes = "oat milk"
+	groceries.Complete()
+
+	cases := []struct {
+		filter string
+		want   []int
+	}{
+		{`completed:false tag:work due<2026-11-01 "quarterly report"`, []int{1}},
+		{`due:2026-10-31`, []int{1}},
+		{`due>2026-10-31`, []int{}},
+		{`due<=2026-10-31`, []int{1}},
+		{`due:none`, []int{2}},
+		{`priority>=high`, []int{1}},
+		{`priority<medium`, []int{2}},
+		{`MILK OR tag:work`, []int{1, 2}},
+		{`-completed:true`, []int{1}},
+		{`id>1`, []int{2}},
+		{`notes:oat description:buy`, []int{2}},
+		{`created<2000-01-01`, []int{}},
+	}
+	for _, c := range cases {
+		expr, err := Parse(c.filter)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", c.filter, err)
+		}
+		got := []int{}
+		for _, task := range []*models.Task{report, groceries} {
+			if expr.Match(task) {
+				got = append(got, task.ID)
+			}
+		}
+		if len(got) != len(c.want) || (len(got) > 0 && got[0] != c.want[0]) {
+			t.Errorf("%q: expected %v, got %v", c.filter, c.want, got)
+		}
+	}
+	// ParseIn reads days in another zone: 17:00 UTC on Oct 31 is already
+	// Nov 1 in Tokyo.
+	tokyo := time.FixedZone("JST", 9*60*60)
+	if expr, _ := ParseIn(`due:2026-11-01`, tokyo); !expr.Match(report) {
+		t.Error("Expected due:2026-11-01 to match in Tokyo")
+	}
+}
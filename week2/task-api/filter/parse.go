@@ -0,0 +1,246 @@
+// Package filter implements the task filter language used by
+// GET /tasks?filter=...:
+//
+//	completed:false tag:work due<2026-11-01 "quarterly report"
+//
+// Terms separated by spaces must all match; OR, NOT (or a leading -)
+// and parentheses combine them further. A term is either a field
+// comparison or free text, which matches the description, notes or
+// tags as a case-insensitive substring.
+//
+// Fields and their operators:
+//
+//	completed:true|false
+//	tag:work
+//	priority:high  priority>=high        (low < medium < high < urgent)
+//	due:2026-11-01 due<2026-11-01 due:none
+//	created>2026-01-01
+//	id:3 id>10
+//	description:word notes:word
+//
+// Dates are YYYY-MM-DD (the whole day, in UTC) or RFC 3339 timestamps.
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SyntaxError reports where a filter could not be parsed.
+type SyntaxError struct {
+	Pos int // byte offset into the filter
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("filter: %s at position %d", e.Msg, e.Pos)
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokMinus
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	end  int
+}
+
+// lex splits a filter into tokens. Words stop at spaces, parentheses,
+// quotes and comparison operators.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	pos := 0
+	for pos < len(input) {
+		c := input[pos]
+		r, size := utf8.DecodeRuneInString(input[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "(", pos, pos + 1})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")", pos, pos + 1})
+			pos++
+		case c == '"':
+			end := strings.IndexByte(input[pos+1:], '"')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: pos, Msg: "unterminated string"}
+			}
+			tokens = append(tokens, token{tokString, input[pos+1 : pos+1+end], pos, pos + end + 2})
+			pos += end + 2
+		case c == ':' || c == '<' || c == '>' || c == '=':
+			end := pos + 1
+			if (c == '<' || c == '>') && end < len(input) && input[end] == '=' {
+				end++
+			}
+			tokens = append(tokens, token{tokOp, input[pos:end], pos, end})
+			pos = end
+		case c == '-' && (len(tokens) == 0 || tokens[len(tokens)-1].end < pos || tokens[len(tokens)-1].kind == tokLParen):
+			tokens = append(tokens, token{tokMinus, "-", pos, pos + 1})
+			pos++
+		default:
+			// The word holds at least the rune at pos, so every pass
+			// moves forward.
+			end := pos + size + strings.IndexFunc(input[pos+size:], func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune(`()":<>=`, r)
+			})
+			if end < pos+size {
+				end = len(input)
+			}
+			tokens = append(tokens, token{tokWord, input[pos:end], pos, end})
+			pos = end
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(input), end: len(input)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	loc    *time.Location
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) keyword(word string) bool {
+	t := p.peek()
+	return t.kind == tokWord && t.text == word
+}
+
+// Parse turns a filter into an expression tree. An empty filter matches
+// every task. YYYY-MM-DD dates are days in UTC.
+func Parse(input string) (Expr, error) {
+	return ParseIn(input, time.UTC)
+}
+
+// ParseIn is Parse with YYYY-MM-DD dates taken as days in loc, for
+// callers such as the CLI that work in local time.
+func ParseIn(input string, loc *time.Location) (Expr, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, loc: loc}
+	if p.peek().kind == tokEOF {
+		return All{}, nil
+	}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return expr, nil
+}
+
+// parseOr: and { "OR" and }
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd: unary { ["AND"] unary }
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind == tokEOF || t.kind == tokRParen || p.keyword("OR") {
+			return left, nil
+		}
+		if p.keyword("AND") {
+			p.next()
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = And{left, right}
+	}
+}
+
+// parseUnary: ("NOT" | "-") unary | primary
+func (p *parser) parseUnary() (Expr, error) {
+	if p.keyword("NOT") || p.peek().kind == tokMinus {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not{x}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary: "(" or ")" | field op value | text
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokLParen:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: "missing )"}
+		}
+		return x, nil
+
+	case tokString:
+		return Text{Value: t.text}, nil
+
+	case tokWord:
+		if t.text == "OR" || t.text == "AND" {
+			return nil, &SyntaxError{Pos: t.pos, Msg: t.text + " needs a term on each side"}
+		}
+		if op := p.peek(); op.kind == tokOp && op.pos == t.end {
+			p.next()
+			value := p.next()
+			if (value.kind != tokWord && value.kind != tokString) || value.pos != op.end {
+				return nil, &SyntaxError{Pos: op.end, Msg: "missing value after " + t.text + op.text}
+			}
+			return newCompare(t, op, value, p.loc)
+		}
+		return Text{Value: t.text}, nil
+
+	case tokEOF:
+		return nil, &SyntaxError{Pos: t.pos, Msg: "unexpected end of filter"}
+	}
+	return nil, &SyntaxError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+}
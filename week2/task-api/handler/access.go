@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"problem"
+	"task-api/middleware"
+	"task-api/models"
+	"task-api/validate"
+)
+
+// AdminRole lets a principal see and change every user's tasks.
+const AdminRole = "admin"
+
+// caller is who a request acts for. Without auth the subject is empty
+// and, like an admin, the caller may see and change everything.
+type caller struct {
+	subject string
+	admin   bool
+	// projects holds the caller's role in each project they belong to.
+	projects map[int]models.ProjectRole
+}
+
+func (h *Handler) callerOf(r *http.Request) (caller, error) {
+	p, ok := middleware.PrincipalFrom(r.Context())
+	if !ok {
+		return caller{}, nil
+	}
+	c := caller{subject: p.Subject, admin: p.HasRole(AdminRole), projects: map[int]models.ProjectRole{}}
+	if c.unrestricted() {
+		return c, nil
+	}
+	projects, err := h.projects.Memberships(c.subject)
+	if err != nil {
+		return c, err
+	}
+	c.projects = projects
+	return c, nil
+}
+
+func (c caller) unrestricted() bool {
+	return c.subject == "" || c.admin
+}
+
+// role is the caller's role in a project, "" for non-members.
+// Unrestricted callers act as owners everywhere.
+func (c caller) role(projectID int) models.ProjectRole {
+	if c.unrestricted() {
+		return models.RoleOwner
+	}
+	return c.projects[projectID]
+}
+
+// require fails unless the caller holds at least need in the project:
+// non-members get 404 so project IDs do not leak, members with a lower
+// role get 403.
+func (c caller) require(projectID int, need models.ProjectRole) error {
+	role := c.role(projectID)
+	if role == "" {
+		return models.ProjectNotFoundError{ID: projectID}
+	}
+	if !role.Allows(need) {
+		return problem.Forbidden(fmt.Sprintf("this needs the %s role in project %d, you are a %s", need, projectID, role))
+	}
+	return nil
+}
+
+// sees reports whether task is visible to the caller: personal tasks to
+// their owner, project tasks to every project member.
+func (c caller) sees(task *models.Task) bool {
+	switch {
+	case c.unrestricted():
+		return true
+	case task.ProjectID != 0:
+		return c.projects[task.ProjectID] != ""
+	}
+	return task.Owner == c.subject
+}
+
+// canEdit fails for tasks in a project where the caller only views.
+func (c caller) canEdit(task *models.Task) error {
+	if task.ProjectID == 0 {
+		return nil
+	}
+	return c.require(task.ProjectID, models.RoleEditor)
+}
+
+// restrict narrows q to the tasks the caller may see.
+func (c caller) restrict(q *models.TaskQuery) {
+	if c.unrestricted() {
+		return
+	}
+	where := q.Where
+	q.Where = func(task *models.Task) bool {
+		return c.sees(task) && (where == nil || where(task))
+	}
+}
+
+// project loads a project the caller holds at least need in.
+func (h *Handler) project(c caller, id int, need models.ProjectRole) (*models.Project, error) {
+	project, err := h.projects.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.require(id, need); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// checkAssignee rejects assigning a task to anyone outside its project.
+// Personal tasks cannot be assigned, here or in the CLI.
+func (h *Handler) checkAssignee(task *models.Task) error {
+	if task.Assignee == "" {
+		return nil
+	}
+	if task.ProjectID == 0 {
+		return validate.Errors{{Field: "assignee", Rule: "project", Message: "only project tasks can be assigned"}}
+	}
+	project, err := h.projects.Get(task.ProjectID)
+	if err != nil {
+		return err
+	}
+	if project.Role(task.Assignee) == "" {
+		return validate.Errors{{Field: "assignee", Rule: "member", Message: "assignee must be a member of the task's project"}}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"problem"
+	"task-api/models"
+	"task-api/storage"
+	"task-api/validate"
+)
+
+// BatchResult reports the outcome of one batch operation. Status is the
+// code the operation would have got as a request of its own.
+type BatchResult struct {
+	Index  int              `json:"index"`
+	Op     string           `json:"op"`
+	Status int              `json:"status"`
+	Task   *models.Task     `json:"task,omitempty"`
+	Error  *problem.Problem `json:"error,omitempty"`
+}
+
+// BatchResponse is the body of a successful POST /tasks:batch.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// batchFailure aborts an atomic batch at the operation that failed.
+type batchFailure struct {
+	result BatchResult
+	err    error
+}
+
+func (f *batchFailure) Error() string {
+	return fmt.Sprintf("operation %d (%s) failed: %v", f.result.Index, f.result.Op, f.err)
+}
+
+// BatchHandler implements POST /tasks:batch. Each operation runs on its
+// own and gets its own result; with ?atomic=true they run in a single
+// store transaction and the first failure rolls every one back.
+func (h *Handler) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.BatchRequest
+	if err := decodeBody(r, &body, true); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validate.Struct(body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	store := h.tasks(r)
+	if r.URL.Query().Get("atomic") != "true" {
+		results := make([]BatchResult, 0, len(body.Operations))
+		for i, op := range body.Operations {
+			result, _ := h.runBatchOp(store, c, i, op)
+			results = append(results, result)
+		}
+		jsonHandler(w, http.StatusOK, BatchResponse{Results: results})
+		return
+	}
+
+	var results []BatchResult
+	err = store.Atomic(func(tx storage.TaskStore) error {
+		results = make([]BatchResult, 0, len(body.Operations))
+		for i, op := range body.Operations {
+			result, err := h.runBatchOp(tx, c, i, op)
+			if err != nil {
+				return &batchFailure{result: result, err: err}
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+
+	var failure *batchFailure
+	switch {
+	case errors.As(err, &failure):
+		problem.Write(w, r, &problem.Error{
+			Status: failure.result.Status,
+			Detail: failure.Error() + "; no operation was applied",
+			Errors: []BatchResult{failure.result},
+			Err:    failure.err,
+		})
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		jsonHandler(w, http.StatusOK, BatchResponse{Results: results})
+	}
+}
+
+// runBatchOp applies one operation to store on behalf of c and describes
+// the outcome. The returned error is nil exactly when the operation
+// succeeded.
+func (h *Handler) runBatchOp(store storage.TaskStore, c caller, index int, op models.BatchOperation) (BatchResult, error) {
+	result := BatchResult{Index: index, Op: op.Op}
+	task, status, err := h.applyBatchOp(store, c, op)
+	if err != nil {
+		err = httpError(err)
+		p := problem.New(err)
+		result.Status = p.Status
+		result.Error = &p
+		return result, err
+	}
+	result.Status = status
+	result.Task = task
+	return result, nil
+}
+
+func (h *Handler) applyBatchOp(store storage.TaskStore, c caller, op models.BatchOperation) (*models.Task, int, error) {
+	if err := validate.Struct(op); err != nil {
+		return nil, 0, err
+	}
+	if op.Op != "create" && op.ID <= 0 {
+		return nil, 0, problem.BadRequest(op.Op + " needs a task id")
+	}
+
+	switch op.Op {
+	case "create":
+		var data models.TaskData
+		if err := decodeOpTask(op.Task, &data, false); err != nil {
+			return nil, 0, err
+		}
+		data.Normalize()
+		if err := validate.Struct(data); err != nil {
+			return nil, 0, err
+		}
+		task, err := h.insertTask(store, c, data)
+		if err != nil {
+			return nil, 0, err
+		}
+		return task, http.StatusCreated, nil
+
+	case "update":
+		var patch models.TaskPatch
+		if err := decodeOpTask(op.Task, &patch, true); err != nil {
+			return nil, 0, err
+		}
+		patch.Normalize()
+		if err := validate.Struct(patch); err != nil {
+			return nil, 0, err
+		}
+		task, err := modifyChanged(store, c, op.ID, func(task *models.Task) error {
+			applyPatch(task, patch)
+			return h.checkAssignee(task)
+		})
+		return task, http.StatusOK, err
+
+	case "complete":
+		task, err := modifyChanged(store, c, op.ID, func(task *models.Task) error {
+			task.SetCompleted(true)
+			return nil
+		})
+		return task, http.StatusOK, err
+
+	default: // delete
+		_, err := modifyLive(store, c, op.ID, func(task *models.Task) error {
+			task.Trash()
+			return nil
+		})
+		return nil, http.StatusNoContent, err
+	}
+}
+
+// decodeOpTask decodes the task member of an operation.
+func decodeOpTask(raw json.RawMessage, v any, strict bool) error {
+	if len(raw) == 0 {
+		return problem.BadRequest("operation needs a task body")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return problem.BadRequest("Invalid task JSON: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+	"problem"
+	"strings"
+	"task-api/models"
+)
+
+// etagMatches reports whether a conditional header value lists etag or is
+// "*". Weak comparison (If-None-Match) ignores a W/ prefix; strong
+// comparison (If-Match) never matches a weak validator.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch fails with 412 when the request carries an If-Match header
+// that does not name the task's current version.
+func checkIfMatch(r *http.Request, task *models.Task) error {
+	header := r.Header.Get("If-Match")
+	if header == "" || etagMatches(header, task.ETag(), false) {
+		return nil
+	}
+	return problem.PreconditionFailed("task " + task.ETag() + " has changed since " + header)
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"problem"
+	"strconv"
+	"strings"
+	"task-api/filter"
+	"task-api/models"
+	"task-api/storage"
+	"time"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// encodeCursor hides the offset so clients treat cursors as opaque.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("bad cursor")
+	}
+	return offset, nil
+}
+
+// parseTaskQuery reads limit, cursor, sort and the filters (filter,
+// owner, assignee, completed, include_deleted, priority, tag, created_after/before,
+// due_after/before) from the query string.
+func parseTaskQuery(values url.Values) (models.TaskQuery, error) {
+	q := models.TaskQuery{Limit: defaultLimit, Sort: values.Get("sort")}
+	if err := q.Validate(); err != nil {
+		return q, err
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		q.Limit = limit
+	}
+
+	if v := values.Get("cursor"); v != "" {
+		offset, err := decodeCursor(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		q.Offset = offset
+	}
+
+	if v := values.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("completed must be true or false")
+		}
+		q.Completed = &completed
+	}
+
+	if v := values.Get("include_deleted"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("include_deleted must be true or false")
+		}
+		q.IncludeDeleted = include
+	}
+
+	if v := values.Get("filter"); v != "" {
+		expr, err := filter.Parse(v)
+		if err != nil {
+			return q, err
+		}
+		q.Where = expr.Match
+	}
+
+	q.Priority = models.Priority(values.Get("priority"))
+	if !q.Priority.Valid() {
+		return q, fmt.Errorf("priority must be one of low, medium, high, urgent")
+	}
+	q.Tag = values.Get("tag")
+	q.Owner = values.Get("owner")
+	q.Assignee = values.Get("assignee")
+
+	for name, dst := range map[string]**time.Time{
+		"created_after":  &q.CreatedAfter,
+		"created_before": &q.CreatedBefore,
+		"due_after":      &q.DueAfter,
+		"due_before":     &q.DueBefore,
+	} {
+		if v := values.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return q, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*dst = &t
+		}
+	}
+	return q, nil
+}
+
+// pageLink rebuilds the request URL with a different cursor.
+func pageLink(r *http.Request, offset int, rel string) string {
+	u := *r.URL
+	values := u.Query()
+	values.Set("cursor", encodeCursor(offset))
+	u.RawQuery = values.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.RequestURI(), rel)
+}
+
+// writeTaskPage queries the store for the tasks c may see according to
+// the request, limited to projectID unless it is 0, and writes the page
+// with X-Total-Count and Link headers alongside the JSON array.
+func (h *Handler) writeTaskPage(w http.ResponseWriter, r *http.Request, c caller, projectID int) {
+	q, err := parseTaskQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, r, problem.BadRequest("Invalid query parameter: "+err.Error()))
+		return
+	}
+	q.ProjectID = projectID
+	c.restrict(&q)
+
+	page, total, err := storage.Query(h.tasks(r), q)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writePage(w, r, q, total, page)
+}
+
+// writeSearchPage is writeTaskPage for search results, which stay in
+// relevance order unless the request asks for a sort.
+func writeSearchPage(w http.ResponseWriter, r *http.Request, c caller, results []models.SearchResult) {
+	q, err := parseTaskQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, r, problem.BadRequest("Invalid query parameter: "+err.Error()))
+		return
+	}
+	c.restrict(&q)
+
+	page, total := q.ApplyRanked(results)
+	writePage(w, r, q, total, page)
+}
+
+func writePage(w http.ResponseWriter, r *http.Request, q models.TaskQuery, total int, page any) {
+	var links []string
+	if next := q.Offset + q.Limit; next < total {
+		links = append(links, pageLink(r, next, "next"))
+	}
+	if q.Offset > 0 {
+		links = append(links, pageLink(r, max(q.Offset-q.Limit, 0), "prev"), pageLink(r, 0, "first"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	jsonHandler(w, http.StatusOK, page)
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"net/http"
+	"problem"
+	"strconv"
+	"task-api/models"
+	"task-api/validate"
+
+	"github.com/gorilla/mux"
+)
+
+// ProjectListHandler lists the projects the caller is a member of.
+func (h *Handler) ProjectListHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	projects, err := h.projects.List()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	visible := []*models.Project{}
+	for _, project := range projects {
+		if c.role(project.ID) != "" {
+			visible = append(visible, project)
+		}
+	}
+	jsonHandler(w, http.StatusOK, visible)
+}
+
+// ProjectCreateHandler creates a project with the caller as its owner.
+func (h *Handler) ProjectCreateHandler(w http.ResponseWriter, r *http.Request) {
+	var body models.ProjectData
+	if err := decodeBody(r, &body, true); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	body.Normalize()
+	if err := validate.Struct(body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	project := models.NewProject(0, body.Name)
+	if c.subject != "" {
+		project.SetMember(c.subject, models.RoleOwner)
+	}
+	if err := h.projects.Create(project); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	jsonHandler(w, http.StatusCreated, project)
+}
+
+func (h *Handler) ProjectHandlerById(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
+
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	project, err := h.project(c, id, models.RoleViewer)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	jsonHandler(w, http.StatusOK, project)
+}
+
+// MemberSetHandler adds a member or changes their role. Only project
+// owners may manage members, and the last owner cannot be demoted.
+func (h *Handler) MemberSetHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
+	subject := vars["subject"]
+
+	var body models.MemberData
+	if err := decodeBody(r, &body, true); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := validate.Struct(body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.updateMembers(w, r, id, func(project *models.Project) error {
+		project.SetMember(subject, body.Role)
+		return nil
+	})
+}
+
+// MemberRemoveHandler takes a member out of the project. Their tasks
+// stay in the project.
+func (h *Handler) MemberRemoveHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
+	subject := vars["subject"]
+
+	h.updateMembers(w, r, id, func(project *models.Project) error {
+		if !project.RemoveMember(subject) {
+			return problem.NotFound(subject + " is not a member of project " + strconv.Itoa(id))
+		}
+		return nil
+	})
+}
+
+// updateMembers applies change as a project owner, refusing to leave a
+// project that had owners without one.
+func (h *Handler) updateMembers(w http.ResponseWriter, r *http.Request, id int, change func(project *models.Project) error) {
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := h.project(c, id, models.RoleOwner); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	project, err := h.projects.Modify(id, func(project *models.Project) error {
+		owners := project.Owners()
+		if err := change(project); err != nil {
+			return err
+		}
+		if owners > 0 && project.Owners() == 0 {
+			return problem.Conflict("project " + strconv.Itoa(id) + " needs at least one owner")
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	jsonHandler(w, http.StatusOK, project)
+}
+
+// ProjectTasksHandler lists a project's tasks; it takes the same query
+// parameters as GET /tasks.
+func (h *Handler) ProjectTasksHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
+
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if _, err := h.project(c, id, models.RoleViewer); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.writeTaskPage(w, r, c, id)
+}
+
+// ProjectCreateTaskHandler is POST /tasks with the project taken from
+// the URL. Viewers are refused.
+func (h *Handler) ProjectCreateTaskHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
+
+	h.createTask(w, r, id)
+}
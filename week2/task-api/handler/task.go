@@ -2,19 +2,74 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
+	"problem"
+	"reflect"
 	"strconv"
-	"strings"
+	"task-api/middleware"
 	"task-api/models"
+	"task-api/search"
 	"task-api/storage"
+	"task-api/validate"
 
 	"github.com/gorilla/mux"
 )
 
-func jsonError(w http.ResponseWriter, message string, code int) {
-	w.Header().Set("Content-type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(models.ErrorResponse{Error: message})
+// Handler serves the task and project routes on top of pluggable stores.
+type Handler struct {
+	store       storage.TaskStore
+	projects    storage.ProjectStore
+	idempotency *middleware.IdempotencyCache
+}
+
+func NewHandler(store storage.TaskStore, projects storage.ProjectStore) *Handler {
+	return &Handler{
+		store:       store,
+		projects:    projects,
+		idempotency: middleware.NewIdempotencyCache(middleware.DefaultIdempotencyTTL),
+	}
+}
+
+// tasks is the task store bound to r, so storage calls can be traced as
+// part of the request.
+func (h *Handler) tasks(r *http.Request) storage.TaskStore {
+	return storage.WithContext(h.store, r.Context())
+}
+
+// Register wires every task route onto router. Unknown routes and
+// methods are answered with problem+json too.
+func (h *Handler) Register(router *mux.Router) {
+	router.NotFoundHandler = problem.Handler(problem.NotFound("no such route"))
+	router.MethodNotAllowedHandler = problem.Handler(problem.MethodNotAllowed("method not allowed on this route"))
+
+	// Specific Route First
+	router.HandleFunc("/tasks", h.SearchHandler).Methods("GET").Queries("q", "{q}")
+
+	//General Route
+	router.HandleFunc("/tasks", h.TaskHandler).Methods("GET")
+
+	router.Handle("/tasks", h.idempotency.Middleware(http.HandlerFunc(h.CreateHandler))).Methods("POST")
+	router.Handle("/tasks:batch", h.idempotency.Middleware(http.HandlerFunc(h.BatchHandler))).Methods("POST")
+	router.HandleFunc("/tasks/{id:[0-9]+}", h.ReplaceHandler).Methods("PUT")
+	router.HandleFunc("/tasks/{id:[0-9]+}", h.PatchHandler).Methods("PATCH")
+	router.HandleFunc("/tasks/{id:[0-9]+}", h.TaskHandlerById).Methods("GET")
+	router.HandleFunc("/tasks/{id:[0-9]+}", h.DeleteHandler).Methods("DELETE")
+
+	// State transitions
+	router.HandleFunc("/tasks/{id:[0-9]+}/complete", h.TaskCompleteHandler).Methods("POST")
+	router.HandleFunc("/tasks/{id:[0-9]+}/reopen", h.TaskReopenHandler).Methods("POST")
+	router.HandleFunc("/tasks/{id:[0-9]+}/restore", h.RestoreHandler).Methods("POST")
+
+	// Projects
+	router.HandleFunc("/projects", h.ProjectListHandler).Methods("GET")
+	router.HandleFunc("/projects", h.ProjectCreateHandler).Methods("POST")
+	router.HandleFunc("/projects/{id:[0-9]+}", h.ProjectHandlerById).Methods("GET")
+	router.HandleFunc("/projects/{id:[0-9]+}/members/{subject}", h.MemberSetHandler).Methods("PUT")
+	router.HandleFunc("/projects/{id:[0-9]+}/members/{subject}", h.MemberRemoveHandler).Methods("DELETE")
+	router.HandleFunc("/projects/{id:[0-9]+}/tasks", h.ProjectTasksHandler).Methods("GET")
+	router.Handle("/projects/{id:[0-9]+}/tasks", h.idempotency.Middleware(http.HandlerFunc(h.ProjectCreateTaskHandler))).Methods("POST")
 }
 
 func jsonHandler(w http.ResponseWriter, code int, data any) {
@@ -26,107 +81,404 @@ func jsonHandler(w http.ResponseWriter, code int, data any) {
 	}
 }
 
-func TaskHandler(w http.ResponseWriter, r *http.Request) {
+// writeError sends err as application/problem+json, translating store and
+// validation errors into their HTTP meaning first. Unexpected errors are
+// logged, since their detail is withheld from the client.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	err = httpError(err)
+	if problem.StatusOf(err) >= http.StatusInternalServerError {
+		middleware.Logger(r.Context()).Error("request failed", "error", err)
+	}
+	problem.Write(w, r, err)
+}
+
+// httpError translates store and validation errors into problem errors.
+func httpError(err error) error {
+	var nf models.TaskNotFoundError
+	var pnf models.ProjectNotFoundError
+	var fieldErrs validate.Errors
+	var syntaxErr *search.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return problem.BadRequest(syntaxErr.Error())
+	case errors.As(err, &nf):
+		return &problem.Error{Status: http.StatusNotFound, Detail: nf.Error(), Err: err}
+	case errors.As(err, &pnf):
+		return &problem.Error{Status: http.StatusNotFound, Detail: pnf.Error(), Err: err}
+	case errors.As(err, &fieldErrs):
+		return problem.Unprocessable("request body failed validation", fieldErrs)
+	}
+	return err
+}
+
+// decodeBody checks the Content-Type and decodes the JSON body into v.
+// In strict mode fields the target does not have are rejected.
+func decodeBody(r *http.Request, v any, strict bool) error {
+	defer r.Body.Close()
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || mediaType != "application/json" {
+			return problem.UnsupportedMediaType("Content-Type must be application/json")
+		}
+	}
+
+	dec := json.NewDecoder(r.Body)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return problem.BadRequest("Invalid JSON: " + err.Error())
+	}
+	return nil
+}
+
+func (h *Handler) TaskHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.writeTaskPage(w, r, c, 0)
+}
+
+// newTask builds an unsaved task from a validated create body.
+func newTask(data models.TaskData) *models.Task {
+	task := models.NewTask(0, data.Description)
+	task.Priority = data.Priority
+	task.DueAt = data.DueAt
+	task.Tags = data.Tags
+	task.Notes = data.Notes
+	task.ProjectID = data.ProjectID
+	task.Assignee = data.Assignee
+	return task
+}
 
-	tasks, _ := storage.LoadTasks(storage.Filename)
-	tm := models.NewTaskManager()
-	tm.Tasks = tasks
-	jsonHandler(w, http.StatusOK, tasks)
+// insertTask stores a new task from a validated create body on behalf of
+// c, who must be able to edit the task's project, if any.
+func (h *Handler) insertTask(store storage.TaskStore, c caller, data models.TaskData) (*models.Task, error) {
+	if data.ProjectID != 0 {
+		if _, err := h.project(c, data.ProjectID, models.RoleEditor); err != nil {
+			return nil, err
+		}
+	}
+	task := newTask(data)
+	task.Owner = c.subject
+	if err := h.checkAssignee(task); err != nil {
+		return nil, err
+	}
+	if err := store.Create(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
 
+func (h *Handler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	h.createTask(w, r, 0)
 }
 
-func CreateHandler(w http.ResponseWriter, r *http.Request) {
+// createTask handles a create body; a non-zero projectID (from the URL)
+// overrides the body's project_id.
+func (h *Handler) createTask(w http.ResponseWriter, r *http.Request, projectID int) {
 
 	var task models.TaskData
-	defer r.Body.Close()
-	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-		jsonError(w, "Invalid Json", http.StatusBadRequest)
+	if err := decodeBody(r, &task, false); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	task.Description = strings.TrimSpace(task.Description)
+	task.Normalize()
+	if projectID != 0 {
+		task.ProjectID = projectID
+	}
+	if err := validate.Struct(task); err != nil {
+		writeError(w, r, err)
+		return
+	}
 
-	if task.Description == "" {
-		jsonError(w, "Description cannot be empty", http.StatusBadRequest)
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	createdTask, err := h.insertTask(h.tasks(r), c, task)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if len(task.Description) < 3 {
-		jsonError(w, "Description is too short (min 3 chars)", http.StatusBadRequest)
+	w.Header().Set("ETag", createdTask.ETag())
+	jsonHandler(w, http.StatusCreated, createdTask)
+}
+
+func (h *Handler) TaskHandlerById(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
+
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	tasks, _ := storage.LoadTasks(storage.Filename)
-	tm := models.NewTaskManager()
-	tm.Tasks = tasks
+	task, err := h.tasks(r).Get(id)
+	if err == nil && !c.sees(task) {
+		err = models.TaskNotFoundError{ID: id}
+	}
+	if err == nil && task.Deleted() && r.URL.Query().Get("include_deleted") != "true" {
+		err = models.TaskNotFoundError{ID: id}
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
-	if len(tasks) > 0 {
-		tm.NextID = tasks[len(tasks)-1].ID + 1
+	w.Header().Set("ETag", task.ETag())
+	if etagMatches(r.Header.Get("If-None-Match"), task.ETag(), true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
+	jsonHandler(w, http.StatusOK, task)
+}
 
-	createdTask := tm.Add(task.Description)
-	storage.SaveTasks(tm.Tasks, storage.Filename)
+// ReplaceHandler implements PUT: the body replaces every editable field.
+func (h *Handler) ReplaceHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
 
-	jsonHandler(w, http.StatusCreated, createdTask)
+	var body models.TaskUpdate
+	if err := decodeBody(r, &body, true); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	body.Normalize()
+	if err := validate.Struct(body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	h.updateTask(w, r, id, func(task *models.Task) {
+		task.Description = body.Description
+		task.SetCompleted(body.Completed)
+		task.Priority = body.Priority
+		task.DueAt = body.DueAt
+		task.Tags = body.Tags
+		task.Notes = body.Notes
+		task.Assignee = body.Assignee
+	})
 }
 
-func TaskHandlerById(w http.ResponseWriter, r *http.Request) {
+// PatchHandler implements PATCH: only the fields present are changed.
+func (h *Handler) PatchHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
 
-	tasks, _ := storage.LoadTasks(storage.Filename)
-	for _, t := range tasks {
-		if t.ID == id {
-			jsonHandler(w, http.StatusOK, t)
-			return
-		}
+	var patch models.TaskPatch
+	if err := decodeBody(r, &patch, true); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	patch.Normalize()
+	if err := validate.Struct(patch); err != nil {
+		writeError(w, r, err)
+		return
 	}
-	jsonError(w, "Task Not Found", http.StatusNotFound)
+
+	h.updateTask(w, r, id, func(task *models.Task) {
+		applyPatch(task, patch)
+	})
 }
 
-func TaskCompleteHandler(w http.ResponseWriter, r *http.Request) {
+// applyPatch changes only the fields present in patch.
+func applyPatch(task *models.Task, patch models.TaskPatch) {
+	if patch.Description != nil {
+		task.Description = *patch.Description
+	}
+	if patch.Completed != nil {
+		task.SetCompleted(*patch.Completed)
+	}
+	if patch.Priority != nil {
+		task.Priority = *patch.Priority
+	}
+	if patch.DueAt.Set {
+		task.DueAt = patch.DueAt.At
+	}
+	if patch.Tags != nil {
+		task.Tags = *patch.Tags
+	}
+	if patch.Notes != nil {
+		task.Notes = *patch.Notes
+	}
+	if patch.Assignee != nil {
+		task.Assignee = *patch.Assignee
+	}
+}
+
+func (h *Handler) TaskCompleteHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
+
+	h.updateTask(w, r, id, func(task *models.Task) {
+		task.SetCompleted(true)
+	})
+}
+
+func (h *Handler) TaskReopenHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
 
-	tasks, _ := storage.LoadTasks(storage.Filename)
-	tm := models.NewTaskManager()
+	h.updateTask(w, r, id, func(task *models.Task) {
+		task.Reopen()
+	})
+}
+
+// modifyEditable is store.Modify for tasks the caller may change. Tasks
+// they cannot see answer as not found, read-only ones as forbidden.
+func modifyEditable(store storage.TaskStore, c caller, id int, change func(task *models.Task) error) (*models.Task, error) {
+	return store.Modify(id, func(task *models.Task) error {
+		if !c.sees(task) {
+			return models.TaskNotFoundError{ID: id}
+		}
+		if err := c.canEdit(task); err != nil {
+			return err
+		}
+		return change(task)
+	})
+}
+
+// modifyLive is modifyEditable for tasks outside the trash; a trashed
+// task answers as not found until it is restored.
+func modifyLive(store storage.TaskStore, c caller, id int, change func(task *models.Task) error) (*models.Task, error) {
+	return modifyEditable(store, c, id, func(task *models.Task) error {
+		if task.Deleted() {
+			return models.TaskNotFoundError{ID: id}
+		}
+		return change(task)
+	})
+}
+
+// errUnchanged aborts a Modify whose change left the task as it was.
+var errUnchanged = errors.New("task unchanged")
+
+// modifyChanged is modifyLive for edits that may turn out to change
+// nothing. Then nothing is written and the stored task comes back with
+// its Version, and so its ETag, as they were.
+func modifyChanged(store storage.TaskStore, c caller, id int, change func(task *models.Task) error) (*models.Task, error) {
+	var unchanged *models.Task
+	task, err := modifyLive(store, c, id, func(task *models.Task) error {
+		before := task.Clone()
+		if err := change(task); err != nil {
+			return err
+		}
+		if reflect.DeepEqual(before, task) {
+			unchanged = before
+			return errUnchanged
+		}
+		return nil
+	})
+	if errors.Is(err, errUnchanged) {
+		return unchanged, nil
+	}
+	return task, err
+}
 
-	tm.Tasks = tasks
-	task := tm.Complete(id)
+// updateTask applies change atomically in the store, honouring If-Match
+// against the version being replaced. A change that leaves the task as
+// it was is not written and keeps the current ETag.
+func (h *Handler) updateTask(w http.ResponseWriter, r *http.Request, id int, change func(task *models.Task)) {
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
-	if task == nil {
-		jsonError(w, "Task Not Found", http.StatusNotFound)
+	task, err := modifyChanged(h.tasks(r), c, id, func(task *models.Task) error {
+		if err := checkIfMatch(r, task); err != nil {
+			return err
+		}
+		change(task)
+		return h.checkAssignee(task)
+	})
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	storage.SaveTasks(tm.Tasks, storage.Filename)
+	w.Header().Set("ETag", task.ETag())
 	jsonHandler(w, http.StatusOK, task)
 }
 
-func DeleteHandler(w http.ResponseWriter, r *http.Request) {
+// DeleteHandler moves the task to the trash. It disappears from lists
+// and lookups but can be restored until it is purged.
+func (h *Handler) DeleteHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, _ := strconv.Atoi(vars["id"])
 
-	tasks, _ := storage.LoadTasks(storage.Filename)
-	tm := models.NewTaskManager()
-	tm.Tasks = tasks
-	if tm.Delete(id) {
-		storage.SaveTasks(tm.Tasks, storage.Filename)
-		w.WriteHeader(http.StatusNoContent)
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	_, err = modifyLive(h.tasks(r), c, id, func(task *models.Task) error {
+		if err := checkIfMatch(r, task); err != nil {
+			return err
+		}
+		task.Trash()
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
-	jsonError(w, "Incorrect Id", http.StatusNotFound)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func SearchHandler(w http.ResponseWriter, r *http.Request) {
-	queryParam := r.URL.Query().Get("q")
-	cleanQuery := strings.Trim(queryParam, " \"")
-	cleanQuery = strings.ToLower(cleanQuery)
+// RestoreHandler takes a task back out of the trash.
+func (h *Handler) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, _ := strconv.Atoi(vars["id"]) // Regex in router ensures this is a number
 
-	tasks, _ := storage.LoadTasks(storage.Filename)
-	tm := models.NewTaskManager()
-	tm.Tasks = tasks
-	results := tm.Search(cleanQuery)
-	jsonHandler(w, http.StatusOK, results)
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	task, err := modifyEditable(h.tasks(r), c, id, func(task *models.Task) error {
+		if err := checkIfMatch(r, task); err != nil {
+			return err
+		}
+		if !task.Deleted() {
+			return problem.Conflict("task " + strconv.Itoa(id) + " is not in the trash")
+		}
+		task.Restore()
+		return nil
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
+	w.Header().Set("ETag", task.ETag())
+	jsonHandler(w, http.StatusOK, task)
+}
+
+// SearchHandler runs a full-text query: words must all match, OR offers
+// alternatives, "quoted phrases" match exactly and buy* matches by
+// prefix. Results come best first with a highlighted snippet.
+func (h *Handler) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := h.callerOf(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	results, err := h.tasks(r).Search(r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	writeSearchPage(w, r, c, results)
 }
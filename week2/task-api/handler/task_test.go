@@ -0,0 +1,914 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"problem"
+	"slices"
+	"strings"
+	"sync"
+	"task-api/middleware"
+	"task-api/models"
+	"task-api/storage"
+	"task-api/validate"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// newTestRouter builds the task routes on top of an in-memory store.
+func newTestRouter(t *testing.T) (*mux.Router, *storage.MemoryStore) {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	router := mux.NewRouter()
+	NewHandler(store, storage.NewMemoryProjectStore()).Register(router)
+	return router, store
+}
+
+func doRequest(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCreateHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+
+	// Case 1: Valid task
+	rec := doRequest(router, "POST", "/tasks", `{"description":"Buy milk"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+	var created models.Task
+	json.NewDecoder(rec.Body).Decode(&created)
+	if created.ID != 1 || created.Description != "Buy milk" {
+		t.Errorf("Unexpected task created: %+v", created)
+	}
+	if tasks, _ := store.List(); len(tasks) != 1 {
+		t.Errorf("Expected 1 stored task, got %d", len(tasks))
+	}
+
+	// Case 2: Validation errors are 422, malformed JSON is 400
+	for body, code := range map[string]int{
+		`{"description":"  "}`: http.StatusUnprocessableEntity,
+		`{"description":"ab"}`: http.StatusUnprocessableEntity,
+		`not json`:             http.StatusBadRequest,
+	} {
+		if rec := doRequest(router, "POST", "/tasks", body); rec.Code != code {
+			t.Errorf("Expected %d for %q, got %d", code, body, rec.Code)
+		}
+	}
+}
+
+func TestGetCompleteDeleteHandlers(t *testing.T) {
+	router, store := newTestRouter(t)
+	store.Create(models.NewTask(0, "Write tests"))
+
+	// Case 1: Get existing and missing task
+	if rec := doRequest(router, "GET", "/tasks/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for existing task, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "GET", "/tasks/99", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for missing task, got %d", rec.Code)
+	}
+
+	// Case 2: Complete persists through the store
+	if rec := doRequest(router, "POST", "/tasks/1/complete", ""); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on complete, got %d", rec.Code)
+	}
+	if task, _ := store.Get(1); !task.Completed || task.CompletedAt == nil {
+		t.Error("Complete was not persisted to the store.")
+	}
+
+	// Case 3: Delete existing, then missing
+	if rec := doRequest(router, "DELETE", "/tasks/1", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 on delete, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "DELETE", "/tasks/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 on second delete, got %d", rec.Code)
+	}
+}
+
+func TestUpdateHandlers(t *testing.T) {
+	router, store := newTestRouter(t)
+	store.Create(models.NewTask(0, "Draft report"))
+
+	decode := func(rec *httptest.ResponseRecorder) models.Task {
+		var task models.Task
+		json.NewDecoder(rec.Body).Decode(&task)
+		return task
+	}
+
+	// Case 1: PATCH changes only the fields sent
+	rec := doRequest(router, "PATCH", "/tasks/1", `{"complete":true}`)
+	if task := decode(rec); rec.Code != http.StatusOK || !task.Completed || task.Description != "Draft report" {
+		t.Errorf("PATCH complete failed: %d %+v", rec.Code, task)
+	}
+	rec = doRequest(router, "PATCH", "/tasks/1", `{"description":"  Final report "}`)
+	if task := decode(rec); task.Description != "Final report" || !task.Completed {
+		t.Errorf("PATCH description failed: %+v", task)
+	}
+
+	// Case 2: PUT replaces everything, so omitting complete reopens
+	rec = doRequest(router, "PUT", "/tasks/1", `{"description":"Rewritten"}`)
+	if task := decode(rec); task.Description != "Rewritten" || task.Completed || task.CompletedAt != nil {
+		t.Errorf("PUT replace failed: %+v", task)
+	}
+
+	// Case 3: complete/reopen transitions
+	doRequest(router, "POST", "/tasks/1/complete", "")
+	rec = doRequest(router, "POST", "/tasks/1/reopen", "")
+	if task := decode(rec); task.Completed || task.CompletedAt != nil {
+		t.Errorf("Reopen failed: %+v", task)
+	}
+
+	// Case 4: Validation matches create, and missing tasks are 404
+	cases := []struct {
+		method, target, body string
+		code                 int
+	}{
+		{"PATCH", "/tasks/1", `{"description":"ab"}`, http.StatusUnprocessableEntity},
+		{"PATCH", "/tasks/1", `{"description":""}`, http.StatusUnprocessableEntity},
+		{"PATCH", "/tasks/1", `{"title":"unknown field"}`, http.StatusBadRequest},
+		{"PUT", "/tasks/1", `{"complete":true}`, http.StatusUnprocessableEntity},
+		{"PUT", "/tasks/99", `{"description":"Valid"}`, http.StatusNotFound},
+		{"POST", "/tasks/99/reopen", "", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if rec := doRequest(router, c.method, c.target, c.body); rec.Code != c.code {
+			t.Errorf("%s %s %s: expected %d, got %d", c.method, c.target, c.body, c.code, rec.Code)
+		}
+	}
+}
+
+func TestListAndSearchHandlers(t *testing.T) {
+	router, store := newTestRouter(t)
+	store.Create(models.NewTask(0, "Buy Groceries"))
+	store.Create(models.NewTask(0, "Write blog post"))
+
+	var tasks []*models.Task
+	rec := doRequest(router, "GET", "/tasks", "")
+	json.NewDecoder(rec.Body).Decode(&tasks)
+	if len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks, got %d", len(tasks))
+	}
+
+	rec = doRequest(router, "GET", `/tasks?q="BUY"`, "")
+	json.NewDecoder(rec.Body).Decode(&tasks)
+	if len(tasks) != 1 || tasks[0].ID != 1 {
+		t.Errorf("Search failed. Expected task 1, got %v", tasks)
+	}
+}
+
+func TestCreateHandler_ParallelPostsGetUniqueIDs(t *testing.T) {
+	store, err := storage.NewCachedStore(storage.NewMemoryStore(), time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewCachedStore failed: %v", err)
+	}
+	defer store.Close()
+	router := mux.NewRouter()
+	NewHandler(store, storage.NewMemoryProjectStore()).Register(router)
+
+	const requests = 40
+	ids := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := doRequest(router, "POST", "/tasks", `{"description":"Parallel"}`)
+			var task models.Task
+			json.NewDecoder(rec.Body).Decode(&task)
+			ids <- task.ID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("Duplicate ID %d handed out", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != requests {
+		t.Errorf("Expected %d distinct IDs, got %d", requests, len(seen))
+	}
+}
+
+func TestListHandler_PagingSortingFiltering(t *testing.T) {
+	router, store := newTestRouter(t)
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		task := models.NewTask(0, "Task")
+		task.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		if i%2 == 0 {
+			task.Complete()
+		}
+		store.Create(task)
+	}
+
+	list := func(target string) ([]*models.Task, *httptest.ResponseRecorder) {
+		rec := doRequest(router, "GET", target, "")
+		var tasks []*models.Task
+		json.NewDecoder(rec.Body).Decode(&tasks)
+		return tasks, rec
+	}
+
+	// Case 1: limit pages through the set with Link and total headers
+	tasks, rec := list("/tasks?limit=2&sort=-created_at")
+	if len(tasks) != 2 || tasks[0].ID != 5 || rec.Header().Get("X-Total-Count") != "5" {
+		t.Fatalf("First page wrong: %v, total %q", tasks, rec.Header().Get("X-Total-Count"))
+	}
+	link := rec.Header().Get("Link")
+	if !strings.Contains(link, `rel="next"`) {
+		t.Fatalf("Expected next link, got %q", link)
+	}
+	next := link[strings.Index(link, "<")+1 : strings.Index(link, ">")]
+	tasks, rec = list(next)
+	if len(tasks) != 2 || tasks[0].ID != 3 || !strings.Contains(rec.Header().Get("Link"), `rel="prev"`) {
+		t.Errorf("Second page wrong: %v, links %q", tasks, rec.Header().Get("Link"))
+	}
+
+	// Case 2: Filters combine
+	tasks, rec = list("/tasks?completed=true&created_after=2025-01-01T00:30:00Z")
+	if len(tasks) != 2 || tasks[0].ID != 3 || tasks[1].ID != 5 || rec.Header().Get("Link") != "" {
+		t.Errorf("Filter wrong: %v", tasks)
+	}
+	tasks, _ = list("/tasks?completed=false&created_before=2025-01-01T02:00:00Z")
+	if len(tasks) != 1 || tasks[0].ID != 2 {
+		t.Errorf("Filter wrong: %v", tasks)
+	}
+
+	// Case 3: Bad parameters are rejected
+	for _, q := range []string{"limit=0", "limit=abc", "sort=name", "completed=maybe", "created_after=yesterday", "cursor=!!"} {
+		if _, rec := list("/tasks?" + q); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestRichTaskFields(t *testing.T) {
+	router, store := newTestRouter(t)
+
+	// Case 1: Create carries priority, due date, tags and notes
+	body := `{"description":"Quarterly report","priority":"high","due_at":"2026-11-01T09:00:00Z","tags":[" work ","Work","finance"],"notes":"Ask finance"}`
+	rec := doRequest(router, "POST", "/tasks", body)
+	var task models.Task
+	json.NewDecoder(rec.Body).Decode(&task)
+	if rec.Code != http.StatusCreated || task.Priority != models.PriorityHigh || task.DueAt == nil || task.Notes != "Ask finance" {
+		t.Fatalf("Create with rich fields failed: %d %+v", rec.Code, task)
+	}
+	if len(task.Tags) != 2 || task.Tags[0] != "work" {
+		t.Errorf("Tags were not normalised: %v", task.Tags)
+	}
+	doRequest(router, "POST", "/tasks", `{"description":"Groceries","priority":"low"}`)
+
+	// Case 2: Invalid priority is rejected everywhere
+	for _, c := range []struct{ method, target, body string }{
+		{"POST", "/tasks", `{"description":"Valid","priority":"asap"}`},
+		{"PUT", "/tasks/1", `{"description":"Valid","priority":"asap"}`},
+		{"PATCH", "/tasks/1", `{"priority":"asap"}`},
+	} {
+		if rec := doRequest(router, c.method, c.target, c.body); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s %s: expected 422, got %d", c.method, c.target, rec.Code)
+		}
+	}
+
+	// Case 3: Filter and search by the new fields
+	var tasks []*models.Task
+	rec = doRequest(router, "GET", "/tasks?tag=FINANCE&priority=high&due_before=2026-12-01T00:00:00Z", "")
+	json.NewDecoder(rec.Body).Decode(&tasks)
+	if len(tasks) != 1 || tasks[0].ID != 1 {
+		t.Errorf("Filter by tag/priority/due failed: %v", tasks)
+	}
+	rec = doRequest(router, "GET", "/tasks?sort=-priority", "")
+	json.NewDecoder(rec.Body).Decode(&tasks)
+	if len(tasks) != 2 || tasks[0].Priority != models.PriorityHigh {
+		t.Errorf("Sort by priority failed: %v", tasks)
+	}
+	rec = doRequest(router, "GET", "/tasks?q=ask", "")
+	json.NewDecoder(rec.Body).Decode(&tasks)
+	if len(tasks) != 1 {
+		t.Errorf("Search in notes failed: %v", tasks)
+	}
+
+	// Case 4: PATCH changes only the sent rich field
+	rec = doRequest(router, "PATCH", "/tasks/1", `{"tags":["urgent-ish"]}`)
+	json.NewDecoder(rec.Body).Decode(&task)
+	if len(task.Tags) != 1 || task.Priority != models.PriorityHigh {
+		t.Errorf("PATCH tags failed: %+v", task)
+	}
+
+	// Case 5: An absent due_at keeps the date, an explicit null clears it
+	for _, c := range []struct {
+		body    string
+		wantDue bool
+	}{
+		{`{"notes":"Ask finance again"}`, true},
+		{`{"due_at":null}`, false},
+	} {
+		var patched models.Task
+		rec = doRequest(router, "PATCH", "/tasks/1", c.body)
+		json.NewDecoder(rec.Body).Decode(&patched)
+		if rec.Code != http.StatusOK || (patched.DueAt != nil) != c.wantDue {
+			t.Errorf("PATCH %s: expected due date kept=%v, got %d %v", c.body, c.wantDue, rec.Code, patched.DueAt)
+		}
+	}
+	if stored, _ := store.Get(1); stored.DueAt != nil {
+		t.Errorf("Cleared due date is still stored: %v", stored.DueAt)
+	}
+}
+
+func TestValidationErrorsAreStructured(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	rec := doRequest(router, "POST", "/tasks", `{"description":"ab","priority":"asap","tags":["this tag is far too long to be accepted as a tag"]}`)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422, got %d", rec.Code)
+	}
+
+	var body struct {
+		Status int                   `json:"status"`
+		Errors []validate.FieldError `json:"errors"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	got := map[string]string{}
+	for _, fe := range body.Errors {
+		got[fe.Field] = fe.Rule
+		if fe.Message == "" {
+			t.Errorf("Missing message for %s", fe.Field)
+		}
+	}
+	want := map[string]string{"description": "min", "priority": "oneof", "tags[0]": "max"}
+	for field, rule := range want {
+		if got[field] != rule {
+			t.Errorf("Field %s: expected rule %q, got %q (all: %v)", field, rule, got[field], got)
+		}
+	}
+}
+
+func TestErrorsAreProblemJSON(t *testing.T) {
+	router, _ := newTestRouter(t)
+
+	cases := []struct {
+		name, method, target, contentType, body string
+		status                                  int
+	}{
+		{"malformed json", "POST", "/tasks", "application/json", "{", http.StatusBadRequest},
+		{"bad query", "GET", "/tasks?limit=0", "", "", http.StatusBadRequest},
+		{"missing task", "GET", "/tasks/42", "", "", http.StatusNotFound},
+		{"unknown route", "GET", "/nope", "", "", http.StatusNotFound},
+		{"wrong method", "DELETE", "/tasks", "", "", http.StatusMethodNotAllowed},
+		{"wrong media type", "POST", "/tasks", "text/plain", `{"description":"Valid"}`, http.StatusUnsupportedMediaType},
+		{"validation", "POST", "/tasks", "application/json; charset=utf-8", `{"description":"x"}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			if c.contentType != "" {
+				req.Header.Set("Content-Type", c.contentType)
+			}
+			req.Header.Set(problem.RequestIDHeader, "req-1")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != c.status {
+				t.Fatalf("Expected %d, got %d", c.status, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != problem.ContentType {
+				t.Errorf("Expected problem+json, got %q", ct)
+			}
+			var p problem.Problem
+			json.NewDecoder(rec.Body).Decode(&p)
+			if p.Status != c.status || p.Title == "" || p.Instance != req.URL.Path || p.RequestID != "req-1" {
+				t.Errorf("Unexpected problem body: %+v", p)
+			}
+		})
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	router, store := newTestRouter(t)
+	store.Create(models.NewTask(0, "Shared task"))
+
+	send := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Case 1: GET returns an ETag and honours If-None-Match
+	rec := send("GET", "/tasks/1", "", nil)
+	etag := rec.Header().Get("ETag")
+	if etag != `"1-1"` {
+		t.Fatalf("Expected ETag \"1-1\", got %q", etag)
+	}
+	if rec := send("GET", "/tasks/1", "", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("Expected empty 304, got %d", rec.Code)
+	}
+
+	// Case 2: First writer with the current ETag wins, the second gets 412
+	rec = send("PATCH", "/tasks/1", `{"notes":"first"}`, map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"1-2"` {
+		t.Fatalf("Expected 200 with new ETag, got %d %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	rec = send("PATCH", "/tasks/1", `{"notes":"second"}`, map[string]string{"If-Match": etag})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for stale ETag, got %d", rec.Code)
+	}
+	if task, _ := store.Get(1); task.Notes != "first" {
+		t.Errorf("Stale write clobbered the task: %q", task.Notes)
+	}
+
+	// Case 3: A write that changes nothing keeps the version and ETag
+	for _, body := range []string{`{}`, `{"notes":"first"}`} {
+		rec = send("PATCH", "/tasks/1", body, map[string]string{"If-Match": `"1-2"`})
+		if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"1-2"` {
+			t.Errorf("PATCH %s: expected 200 with ETag \"1-2\", got %d %q", body, rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+	if task, _ := store.Get(1); task.Version != 2 {
+		t.Errorf("No-op PATCH bumped the version to %d", task.Version)
+	}
+
+	// Case 4: Old ETag no longer short-circuits GET; weak tags never satisfy If-Match
+	if rec := send("GET", "/tasks/1", "", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 for stale If-None-Match, got %d", rec.Code)
+	}
+	if rec := send("POST", "/tasks/1/complete", "", map[string]string{"If-Match": `W/"1-2"`}); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for weak If-Match, got %d", rec.Code)
+	}
+
+	// Case 5: DELETE honours If-Match too
+	if rec := send("DELETE", "/tasks/1", "", map[string]string{"If-Match": etag}); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 on stale delete, got %d", rec.Code)
+	}
+	if rec := send("DELETE", "/tasks/1", "", map[string]string{"If-Match": `"1-2"`}); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 on matching delete, got %d", rec.Code)
+	}
+}
+
+func TestCreateHandler_IdempotencyKey(t *testing.T) {
+	router, store := newTestRouter(t)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Case 1: A retried POST creates the task only once
+	first := post("retry-1", `{"description":"Buy milk"}`)
+	second := post("retry-1", `{"description":"Buy milk"}`)
+	if first.Code != http.StatusCreated || second.Code != http.StatusCreated {
+		t.Fatalf("Expected 201 twice, got %d and %d", first.Code, second.Code)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("Replay body differs: %q vs %q", first.Body, second.Body)
+	}
+	if tasks, _ := store.List(); len(tasks) != 1 {
+		t.Errorf("Expected 1 task, got %d", len(tasks))
+	}
+
+	// Case 2: Reusing the key for another body is rejected
+	if rec := post("retry-1", `{"description":"Buy bread"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422, got %d", rec.Code)
+	}
+
+	// Case 3: Invalid bodies with a key still fail validation
+	if rec := post("bad", `{}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for invalid body, got %d", rec.Code)
+	}
+	if tasks, _ := store.List(); len(tasks) != 1 {
+		t.Errorf("Expected still 1 task, got %d", len(tasks))
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	router, store := newTestRouter(t)
+	store.Create(models.NewTask(0, "Existing task"))
+
+	decode := func(rec *httptest.ResponseRecorder) []BatchResult {
+		var body BatchResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode batch response: %v", err)
+		}
+		return body.Results
+	}
+
+	// Case 1: Independent operations each get their own result
+	rec := doRequest(router, "POST", "/tasks:batch", `{"operations":[
+		{"op":"create","task":{"description":"Imported one","tags":["import"]}},
+		{"op":"complete","id":1},
+		{"op":"update","id":1,"task":{"notes":"done in batch"}},
+		{"op":"delete","id":42},
+		{"op":"create","task":{"description":"x"}},
+		{"op":"archive","id":1}
+	]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	results := decode(rec)
+	wantStatus := []int{201, 200, 200, 404, 422, 422}
+	if len(results) != len(wantStatus) {
+		t.Fatalf("Expected %d results, got %d", len(wantStatus), len(results))
+	}
+	for i, want := range wantStatus {
+		if results[i].Index != i || results[i].Status != want {
+			t.Errorf("Result %d: expected status %d, got %+v", i, want, results[i])
+		}
+	}
+	if results[3].Error == nil || results[3].Error.Detail == "" {
+		t.Errorf("Expected problem details on failed item, got %+v", results[3])
+	}
+	if task, _ := store.Get(1); !task.Completed || task.Notes != "done in batch" || task.Version != 3 {
+		t.Errorf("Unexpected task after batch: %+v", task)
+	}
+	if tasks, _ := store.List(); len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks, got %d", len(tasks))
+	}
+
+	// Case 2: Atomic mode rolls everything back on the first failure
+	rec = doRequest(router, "POST", "/tasks:batch?atomic=true", `{"operations":[
+		{"op":"create","task":{"description":"Never stored"}},
+		{"op":"delete","id":1},
+		{"op":"complete","id":42}
+	]}`)
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("Expected 404 problem, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if tasks, _ := store.List(); len(tasks) != 2 {
+		t.Errorf("Atomic batch partly applied: %d tasks", len(tasks))
+	}
+
+	// Case 3: Atomic mode applies everything when all succeed
+	rec = doRequest(router, "POST", "/tasks:batch?atomic=true", `{"operations":[
+		{"op":"create","task":{"description":"Stored one"}},
+		{"op":"create","task":{"description":"Stored two"}},
+		{"op":"delete","id":1}
+	]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if results := decode(rec); len(results) != 3 || results[1].Task == nil || results[1].Task.ID != 4 {
+		t.Errorf("Unexpected atomic results: %+v", results)
+	}
+	if tasks, _ := store.List(); len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks, got %d", len(tasks))
+	}
+	if task, _ := store.Get(1); !task.Deleted() {
+		t.Errorf("Expected task 1 in the trash, got %+v", task)
+	}
+
+	// Case 4: Empty batches are rejected
+	if rec := doRequest(router, "POST", "/tasks:batch", `{"operations":[]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for empty batch, got %d", rec.Code)
+	}
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	router, store := newTestRouter(t)
+	store.Create(models.NewTask(0, "Keep me"))
+	store.Create(models.NewTask(0, "Oops"))
+
+	// Case 1: Deleted tasks are hidden from lists, search and lookups
+	if rec := doRequest(router, "DELETE", "/tasks/2", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 on delete, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "GET", "/tasks", ""); rec.Header().Get("X-Total-Count") != "1" {
+		t.Errorf("Expected 1 listed task, got %s", rec.Header().Get("X-Total-Count"))
+	}
+	if rec := doRequest(router, "GET", "/tasks?q=oops", ""); rec.Header().Get("X-Total-Count") != "0" {
+		t.Errorf("Expected deleted task hidden from search, got %s", rec.Header().Get("X-Total-Count"))
+	}
+	for _, req := range [][2]string{{"GET", "/tasks/2"}, {"PATCH", "/tasks/2"}, {"POST", "/tasks/2/complete"}} {
+		if rec := doRequest(router, req[0], req[1], `{"notes":"x"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404 for deleted task, got %d", req[0], req[1], rec.Code)
+		}
+	}
+
+	// Case 2: include_deleted shows them again
+	rec := doRequest(router, "GET", "/tasks?include_deleted=true", "")
+	var tasks []models.Task
+	json.NewDecoder(rec.Body).Decode(&tasks)
+	if len(tasks) != 2 || tasks[1].DeletedAt == nil {
+		t.Errorf("Expected 2 tasks with deleted_at on the second, got %+v", tasks)
+	}
+	if rec := doRequest(router, "GET", "/tasks/2?include_deleted=true", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with include_deleted, got %d", rec.Code)
+	}
+
+	// Case 3: Restore brings the task back, only once
+	rec = doRequest(router, "POST", "/tasks/2/restore", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 on restore, got %d", rec.Code)
+	}
+	if task, _ := store.Get(2); task.Deleted() || task.Version != 3 {
+		t.Errorf("Expected restored task at version 3, got %+v", task)
+	}
+	if rec := doRequest(router, "POST", "/tasks/2/restore", ""); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 restoring a live task, got %d", rec.Code)
+	}
+	if rec := doRequest(router, "POST", "/tasks/99/restore", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 restoring a missing task, got %d", rec.Code)
+	}
+}
+
+func TestSearchHandler_FullText(t *testing.T) {
+	router, store := newTestRouter(t)
+	store.Create(models.NewTask(0, "Buy oat milk"))
+	store.Create(models.NewTask(0, "Write quarterly report"))
+	notes := models.NewTask(0, "Plan week")
+	notes.Notes = "remember the milk"
+	store.Create(notes)
+
+	search := func(query string) []models.SearchResult {
+		t.Helper()
+		rec := doRequest(router, "GET", "/tasks?q="+url.QueryEscape(query), "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", query, rec.Code, rec.Body)
+		}
+		var results []models.SearchResult
+		json.NewDecoder(rec.Body).Decode(&results)
+		return results
+	}
+
+	// Case 1: Description matches rank above notes, with highlighted snippets
+	results := search("MILK")
+	if len(results) != 2 || results[0].ID != 1 || results[1].ID != 3 {
+		t.Fatalf("Expected tasks 1 then 3, got %+v", results)
+	}
+	if results[0].Snippet != "Buy oat <mark>milk</mark>" || results[0].Score <= results[1].Score {
+		t.Errorf("Unexpected ranking or snippet: %+v", results)
+	}
+
+	// Case 2: Phrases, prefixes and OR
+	if results := search(`"quarterly report" OR buy*`); len(results) != 2 {
+		t.Errorf("Expected 2 results, got %+v", results)
+	}
+	if results := search(`"milk oat"`); len(results) != 0 {
+		t.Errorf("Phrase matched out of order: %+v", results)
+	}
+
+	// Case 3: The index follows updates and deletes
+	doRequest(router, "PATCH", "/tasks/1", `{"description":"Buy bread"}`)
+	doRequest(router, "DELETE", "/tasks/3", "")
+	if results := search("milk"); len(results) != 0 {
+		t.Errorf("Expected no milk after update and delete, got %+v", results)
+	}
+	if results := search("bread"); len(results) != 1 {
+		t.Errorf("Expected updated task to be found, got %+v", results)
+	}
+
+	// Case 4: Malformed queries are a 400 with the position
+	rec := doRequest(router, "GET", "/tasks?q="+url.QueryEscape(`"oat milk`), "")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "position 0") {
+		t.Errorf("Expected 400 with position, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestListHandler_Filter(t *testing.T) {
+	router, store := newTestRouter(t)
+	due := time.Date(2026, 10, 30, 0, 0, 0, 0, time.UTC)
+	report := models.NewTask(0, "Write quarterly report")
+	report.Tags, report.DueAt = []string{"work"}, &due
+	store.Create(report)
+	store.Create(models.NewTask(0, "Quarterly report for home"))
+	done := models.NewTask(0, "Old quarterly report")
+	done.Tags = []string{"work"}
+	done.Complete()
+	store.Create(done)
+
+	list := func(filter string) *httptest.ResponseRecorder {
+		return doRequest(router, "GET", "/tasks?filter="+url.QueryEscape(filter), "")
+	}
+
+	// Case 1: Fields, comparisons and phrases combine
+	rec := list(`completed:false tag:work due<2026-11-01 "quarterly report"`)
+	var tasks []*models.Task
+	json.NewDecoder(rec.Body).Decode(&tasks)
+	if rec.Code != http.StatusOK || len(tasks) != 1 || tasks[0].ID != 1 {
+		t.Errorf("Expected only task 1, got %d %v", rec.Code, tasks)
+	}
+
+	// Case 2: Filters combine with search and the other parameters
+	rec = doRequest(router, "GET", "/tasks?q=report&completed=false&filter="+url.QueryEscape("-tag:work"), "")
+	if rec.Header().Get("X-Total-Count") != "1" {
+		t.Errorf("Expected 1 match, got %s", rec.Header().Get("X-Total-Count"))
+	}
+
+	// Case 3: Parse errors are 400s naming the position
+	rec = list(`tag:work due<soon`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "position 13") {
+		t.Errorf("Expected 400 at position 13, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+// as serves requests on router as an authenticated principal.
+func as(router http.Handler, subject string, roles ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := &middleware.Principal{Subject: subject, Roles: roles, Method: "api_key"}
+		router.ServeHTTP(w, r.WithContext(middleware.WithPrincipal(r.Context(), p)))
+	})
+}
+
+func TestOwnership(t *testing.T) {
+	router, store := newTestRouter(t)
+	alice, bob, admin := as(router, "alice"), as(router, "bob"), as(router, "root", AdminRole)
+
+	doRequest(alice, "POST", "/tasks", `{"description":"Alice's report"}`)
+	doRequest(bob, "POST", "/tasks", `{"description":"Bob's report"}`)
+
+	// Case 1: New tasks record their owner
+	if task, _ := store.Get(1); task.Owner != "alice" {
+		t.Errorf("Expected owner alice, got %q", task.Owner)
+	}
+
+	// Case 2: Lists and searches only show the caller's tasks
+	for _, target := range []string{"/tasks", "/tasks?q=report"} {
+		var tasks []models.Task
+		rec := doRequest(bob, "GET", target, "")
+		json.NewDecoder(rec.Body).Decode(&tasks)
+		if len(tasks) != 1 || tasks[0].ID != 2 || rec.Header().Get("X-Total-Count") != "1" {
+			t.Errorf("%s: expected only task 2 for bob, got %+v", target, tasks)
+		}
+	}
+	if rec := doRequest(bob, "GET", "/tasks?owner=alice", ""); strings.Contains(rec.Body.String(), "Alice's") {
+		t.Errorf("Owner filter escaped the caller's scope: %s", rec.Body)
+	}
+
+	// Case 3: Someone else's task is not found, whatever the route
+	for _, req := range [][2]string{
+		{"GET", "/tasks/1"},
+		{"PATCH", "/tasks/1"},
+		{"POST", "/tasks/1/complete"},
+		{"DELETE", "/tasks/1"},
+		{"POST", "/tasks/1/restore"},
+	} {
+		if rec := doRequest(bob, req[0], req[1], `{"notes":"mine now"}`); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404 for bob, got %d", req[0], req[1], rec.Code)
+		}
+	}
+	rec := doRequest(bob, "POST", "/tasks:batch", `{"operations":[{"op":"complete","id":1}]}`)
+	if !strings.Contains(rec.Body.String(), `"status":404`) {
+		t.Errorf("Batch touched another user's task: %s", rec.Body)
+	}
+	if task, _ := store.Get(1); task.Completed || task.Deleted() || task.Notes != "" {
+		t.Errorf("Bob changed alice's task: %+v", task)
+	}
+
+	// Case 4: Admins see and change everything and may filter by owner
+	var tasks []models.Task
+	json.NewDecoder(doRequest(admin, "GET", "/tasks", "").Body).Decode(&tasks)
+	if len(tasks) != 2 {
+		t.Errorf("Expected admin to see 2 tasks, got %d", len(tasks))
+	}
+	tasks = nil
+	json.NewDecoder(doRequest(admin, "GET", "/tasks?owner=alice", "").Body).Decode(&tasks)
+	if len(tasks) != 1 || tasks[0].Owner != "alice" {
+		t.Errorf("Expected admin owner filter to return alice's task, got %+v", tasks)
+	}
+	if rec := doRequest(admin, "POST", "/tasks/1/complete", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected admin to complete alice's task, got %d", rec.Code)
+	}
+
+	// Case 5: Idempotency keys are per caller
+	req := func(h http.Handler) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{"description":"Keyed task"}`))
+		r.Header.Set(middleware.IdempotencyKeyHeader, "k1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+	req(alice)
+	if rec := req(bob); rec.Header().Get("Idempotent-Replayed") != "" || !strings.Contains(rec.Body.String(), `"owner":"bob"`) {
+		t.Errorf("Bob got alice's idempotent response: %s", rec.Body)
+	}
+}
+
+func TestProjects(t *testing.T) {
+	router, store := newTestRouter(t)
+	alice, bob, carol := as(router, "alice"), as(router, "bob"), as(router, "carol")
+
+	// Case 1: The creator owns the project and adds members
+	rec := doRequest(alice, "POST", "/projects", `{"name":"Launch"}`)
+	var project models.Project
+	json.NewDecoder(rec.Body).Decode(&project)
+	if rec.Code != http.StatusCreated || project.ID != 1 || project.Role("alice") != models.RoleOwner {
+		t.Fatalf("Expected project 1 owned by alice, got %d %+v", rec.Code, project)
+	}
+	doRequest(alice, "PUT", "/projects/1/members/bob", `{"role":"editor"}`)
+	doRequest(alice, "PUT", "/projects/1/members/carol", `{"role":"viewer"}`)
+	if rec := doRequest(alice, "PUT", "/projects/1/members/dave", `{"role":"boss"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for unknown role, got %d", rec.Code)
+	}
+
+	// Case 2: Editors create and assign project tasks, viewers cannot
+	rec = doRequest(bob, "POST", "/projects/1/tasks", `{"description":"Write launch post","assignee":"carol"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected editor to create a task, got %d: %s", rec.Code, rec.Body)
+	}
+	if task, _ := store.Get(1); task.ProjectID != 1 || task.Assignee != "carol" {
+		t.Errorf("Expected task in project 1 assigned to carol, got %+v", task)
+	}
+	if rec := doRequest(carol, "POST", "/tasks", `{"description":"Sneaky","project_id":1}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a viewer creating a task, got %d", rec.Code)
+	}
+	if rec := doRequest(bob, "PATCH", "/tasks/1", `{"assignee":"mallory"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for assigning a non-member, got %d", rec.Code)
+	}
+	if rec := doRequest(alice, "POST", "/tasks", `{"description":"Personal","assignee":"bob"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for assigning a personal task, got %d", rec.Code)
+	}
+
+	// Case 3: Every member sees project tasks; viewers cannot change them
+	for name, h := range map[string]http.Handler{"alice": alice, "carol": carol} {
+		var tasks []models.Task
+		json.NewDecoder(doRequest(h, "GET", "/projects/1/tasks?assignee=carol", "").Body).Decode(&tasks)
+		if len(tasks) != 1 {
+			t.Errorf("%s: expected 1 project task, got %d", name, len(tasks))
+		}
+	}
+	for _, req := range [][2]string{{"PATCH", "/tasks/1"}, {"POST", "/tasks/1/complete"}, {"DELETE", "/tasks/1"}} {
+		if rec := doRequest(carol, req[0], req[1], `{"notes":"x"}`); rec.Code != http.StatusForbidden {
+			t.Errorf("%s %s: expected 403 for viewer, got %d", req[0], req[1], rec.Code)
+		}
+	}
+	rec = doRequest(carol, "POST", "/tasks:batch", `{"operations":[{"op":"complete","id":1}]}`)
+	if !strings.Contains(rec.Body.String(), `"status":403`) {
+		t.Errorf("Expected batch op by viewer to be forbidden: %s", rec.Body)
+	}
+	if rec := doRequest(alice, "POST", "/tasks/1/complete", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected owner to complete the task, got %d", rec.Code)
+	}
+
+	// Case 4: Outsiders see neither the project nor its tasks
+	dave := as(router, "dave")
+	for _, target := range []string{"/projects/1", "/projects/1/tasks", "/tasks/1"} {
+		if rec := doRequest(dave, "GET", target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404 for outsider, got %d", target, rec.Code)
+		}
+	}
+	if rec := doRequest(dave, "GET", "/projects", ""); strings.TrimSpace(rec.Body.String()) != "[]" {
+		t.Errorf("Expected outsider to list no projects, got %s", rec.Body)
+	}
+
+	// Case 5: Only owners manage members, and the last owner stays
+	if rec := doRequest(bob, "PUT", "/projects/1/members/dave", `{"role":"viewer"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for editor managing members, got %d", rec.Code)
+	}
+	if rec := doRequest(alice, "PUT", "/projects/1/members/alice", `{"role":"viewer"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for demoting the last owner, got %d", rec.Code)
+	}
+	if rec := doRequest(alice, "DELETE", "/projects/1/members/carol", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 removing carol, got %d", rec.Code)
+	}
+	if rec := doRequest(carol, "GET", "/tasks/1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected removed member to lose access, got %d", rec.Code)
+	}
+}
+
+func TestStorageCallsCarryTheRequestContext(t *testing.T) {
+	type key struct{}
+	var (
+		mu  sync.Mutex
+		ops []string
+	)
+	store := &storage.ObservedStore{
+		TaskStore: storage.NewMemoryStore(),
+		Observe: func(ctx context.Context, op string, d time.Duration, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			ops = append(ops, fmt.Sprintf("%s:%v", op, ctx.Value(key{})))
+		},
+	}
+	router := mux.NewRouter()
+	NewHandler(store, storage.NewMemoryProjectStore()).Register(router)
+
+	for i, target := range []string{"/tasks", "/tasks?q=milk"} {
+		req := httptest.NewRequest("GET", target, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req.WithContext(context.WithValue(req.Context(), key{}, i)))
+	}
+	rec := doRequest(router, "POST", "/tasks", `{"description":"Buy milk"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d", rec.Code)
+	}
+	if want := []string{"query:0", "search:1", "create:<nil>"}; !slices.Equal(ops, want) {
+		t.Errorf("Expected %v, got %v", want, ops)
+	}
+}
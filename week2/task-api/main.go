@@ -1,31 +1,247 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"server"
+	"syscall"
+	"task-api/config"
 	"task-api/handler"
 	"task-api/middleware"
+	"task-api/storage"
+	"task-api/tracing"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// openStore builds the TaskStore selected by storage.kind and the
+// ProjectStore kept alongside it. The sqlite and wal backends hold
+// projects themselves and import the projects file on first start; the
+// json backend keeps using that file.
+func openStore(cfg *config.Config) (storage.TaskStore, storage.ProjectStore, error) {
+	switch cfg.Storage.Kind {
+	case "json":
+		projects, err := storage.NewJSONProjectStore(cfg.Path(cfg.Storage.Projects))
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage.NewJSONStore(cfg.Path(cfg.Storage.TasksFile)), projects, nil
+	case "sqlite":
+		store, err := storage.NewSQLiteStore(cfg.Path(cfg.Storage.DB))
+		if err != nil {
+			return nil, nil, err
+		}
+		return importProjects(cfg, store, store.Projects())
+	case "wal":
+		store, err := storage.NewLogStore(cfg.Path(cfg.Storage.WAL))
+		if err != nil {
+			return nil, nil, err
+		}
+		return importProjects(cfg, store, store.Projects())
+	}
+	return nil, nil, fmt.Errorf("unknown storage %q (want json, sqlite or wal)", cfg.Storage.Kind)
+}
+
+// importProjects copies the projects file into a backend that keeps its
+// own projects, the first time it starts, and closes the backend if
+// that fails.
+func importProjects(cfg *config.Config, store storage.TaskStore, projects storage.ProjectStore) (storage.TaskStore, storage.ProjectStore, error) {
+	path := cfg.Path(cfg.Storage.Projects)
+	n, err := storage.ImportProjects(projects, path)
+	if err != nil {
+		if closer, ok := store.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, nil, fmt.Errorf("importing %s: %w", path, err)
+	}
+	if n > 0 {
+		slog.Info("imported projects", "count", n, "from", path)
+	}
+	return store, projects, nil
+}
+
+// newTracer builds the tracer selected by tracing.exporter, or returns
+// nil when tracing is off.
+func newTracer(cfg *config.Config) (*tracing.Tracer, error) {
+	var exporter tracing.Exporter
+	switch cfg.Tracing.Exporter {
+	case "none":
+		return nil, nil
+	case "stdout":
+		exporter = tracing.NewWriterExporter(os.Stdout)
+	case "file":
+		file, err := tracing.NewFileExporter(cfg.Tracing.File)
+		if err != nil {
+			return nil, err
+		}
+		exporter = file
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q (want none, stdout or file)", cfg.Tracing.Exporter)
+	}
+	return tracing.NewTracer(exporter, func(err error) {
+		slog.Warn("exporting span failed", "error", err)
+	}), nil
+}
+
+// purgeLoop permanently removes tasks that have been in the trash for
+// longer than days, once at startup and then every hour until ctx is
+// done.
+func purgeLoop(ctx context.Context, store storage.TaskStore, days int) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		cutoff := time.Now().AddDate(0, 0, -days)
+		if n, err := storage.Purge(store, cutoff); err != nil {
+			slog.Error("purge failed", "error", err)
+		} else if n > 0 {
+			slog.Info("purged deleted tasks", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// withEndpoint serves path with h and every other path with next. It
+// keeps the endpoint out of the router's middleware, auth included.
+func withEndpoint(path string, h, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == path {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func main() {
+	if err := run(); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Printf("task-api: %v", err)
+		os.Exit(1)
+	}
+}
 
-	router := mux.NewRouter()
+// run serves the API until SIGINT or SIGTERM, then drains in-flight
+// requests and writes out every pending change before returning.
+func run() (err error) {
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err != nil {
+		return err
+	}
+	if cfg.PrintConfig {
+		return cfg.Print(os.Stdout)
+	}
+
+	logger, err := middleware.NewLogger(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		return err
+	}
+	// Route the standard log package, used by storage and server, through
+	// the same handler.
+	slog.SetDefault(logger)
+
+	backend, projects, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	if closer, ok := backend.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	tracer, err := newTracer(cfg)
+	if err != nil {
+		return err
+	}
+	if tracer != nil {
+		// Deferred before the store's Close so the final flush is traced.
+		defer tracer.Shutdown(context.Background())
+	}
+
+	metrics := middleware.NewMetrics()
+	observed := &storage.ObservedStore{TaskStore: backend, Observe: metrics.ObserveStorage, Names: storage.BackendNames}
+	if tracer != nil {
+		observed.Observe = func(ctx context.Context, op string, d time.Duration, err error) {
+			metrics.ObserveStorage(ctx, op, d, err)
+			tracer.ObserveStorage(ctx, op, d, err)
+		}
+	}
 
-	router.Use(middleware.LoggingMiddleware)
-	router.Use(middleware.CorsMiddleware)
-	// Specific Route First
-	router.HandleFunc("/tasks", handler.SearchHandler).Methods("GET").Queries("q", "{q}")
+	// One TaskManager for the whole process; the backend is only read at
+	// startup and written in the background.
+	store, err := storage.NewCachedStore(observed, time.Duration(cfg.Storage.Flush))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Close flushes the writes still queued in memory.
+		if closeErr := store.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("saving pending changes: %w", closeErr)
+		}
+	}()
 
-	//General Route
-	router.HandleFunc("/tasks", handler.TaskHandler).Methods("GET")
+	metrics.WatchTasks(store)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.Storage.PurgeDays > 0 {
+		go purgeLoop(ctx, store, cfg.Storage.PurgeDays)
+	}
+
+	corsConfig := middleware.DefaultCORSConfig()
+	corsConfig.AllowedOrigins = cfg.CORS.Origins
+	corsConfig.AllowCredentials = cfg.CORS.Credentials
+	corsConfig.MaxAge = time.Duration(cfg.CORS.MaxAge)
+	cors, err := middleware.NewCORS(corsConfig)
+	if err != nil {
+		return err
+	}
+
+	router := mux.NewRouter()
 
-	router.HandleFunc("/tasks", handler.CreateHandler).Methods("POST")
-	router.HandleFunc("/tasks/{id:[0-9]+}", handler.TaskCompleteHandler).Methods("PUT")
-	router.HandleFunc("/tasks/{id:[0-9]+}", handler.TaskHandlerById).Methods("GET")
-	router.HandleFunc("/tasks/{id:[0-9]+}", handler.DeleteHandler).Methods("DELETE")
+	router.Use(middleware.RouteTemplate, metrics.Middleware)
+	if cfg.Auth != "" {
+		authConfig, err := middleware.LoadAuthConfig(cfg.Auth)
+		if err != nil {
+			return err
+		}
+		auth, err := middleware.NewAuthenticator(authConfig)
+		if err != nil {
+			return err
+		}
+		router.Use(auth.Middleware)
+	} else {
+		logger.Warn("auth not configured, every request is allowed")
+	}
+	// Requests are served from the cache, so that is where their storage
+	// spans come from; the handlers bind each call to its request.
+	var tasks storage.TaskStore = store
+	if tracer != nil {
+		tasks = &storage.ObservedStore{TaskStore: store, Observe: tracer.ObserveStorage}
+	}
+	handler.NewHandler(tasks, projects).Register(router)
 
-	fmt.Println("Starting server at 8080...")
-	http.ListenAndServe(":8080", router)
+	var app http.Handler = router
+	if cfg.MetricsPath != "" {
+		app = withEndpoint(cfg.MetricsPath, metrics.Handler(), router)
+	}
+	app = middleware.AccessLog(logger)(cors.Middleware(app))
+	if tracer != nil {
+		app = middleware.Tracing(tracer)(app)
+	}
+	return server.Run(ctx, cfg.ServerConfig(), app)
 }
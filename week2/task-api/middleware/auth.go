@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"problem"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// APIKeyHeader carries a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	Subject string
+	Roles   []string
+	Method  string // "api_key" or "jwt"
+}
+
+// HasRole reports whether the principal was granted role.
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal Authenticator attached to ctx.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// APIKey is one static key from the auth config file.
+type APIKey struct {
+	Key     string   `json:"key"`
+	Subject string   `json:"subject"`
+	Roles   []string `json:"roles"`
+}
+
+// JWTConfig describes which bearer tokens are accepted. At least one of
+// HS256Secret and RS256PublicKeyFile must be set.
+type JWTConfig struct {
+	Issuer             string `json:"issuer"`
+	Audience           string `json:"audience"`
+	HS256Secret        string `json:"hs256_secret"`
+	RS256PublicKeyFile string `json:"rs256_public_key_file"`
+	// ClockSkew is how far exp, nbf and iat may be off, e.g. "30s".
+	ClockSkew string `json:"clock_skew"`
+}
+
+// AuthConfig is the JSON auth config file.
+type AuthConfig struct {
+	APIKeys []APIKey   `json:"api_keys"`
+	JWT     *JWTConfig `json:"jwt"`
+}
+
+// LoadAuthConfig reads an AuthConfig from a JSON file.
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg AuthConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// tokenClaims are the JWT claims task-api reads.
+type tokenClaims struct {
+	Roles []string `json:"roles"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator checks every request for an API key (X-API-Key) or a
+// JWT bearer token and attaches the resulting Principal to the request
+// context. Requests without valid credentials get a 401.
+type Authenticator struct {
+	keys      map[[32]byte]APIKey // by SHA-256 of the key
+	hmacKey   []byte
+	rsaKey    *rsa.PublicKey
+	parser    *jwt.Parser
+	jwtConfig bool
+}
+
+func NewAuthenticator(cfg *AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{keys: map[[32]byte]APIKey{}}
+	for i, key := range cfg.APIKeys {
+		if key.Key == "" || key.Subject == "" {
+			return nil, fmt.Errorf("api_keys[%d]: key and subject are required", i)
+		}
+		a.keys[sha256.Sum256([]byte(key.Key))] = key
+	}
+
+	if cfg.JWT == nil {
+		return a, nil
+	}
+	j := cfg.JWT
+	var methods []string
+	if j.HS256Secret != "" {
+		a.hmacKey = []byte(j.HS256Secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if j.RS256PublicKeyFile != "" {
+		pem, err := os.ReadFile(j.RS256PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if a.rsaKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return nil, fmt.Errorf("%s: %w", j.RS256PublicKeyFile, err)
+		}
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("jwt: set hs256_secret or rs256_public_key_file")
+	}
+
+	var skew time.Duration
+	if j.ClockSkew != "" {
+		var err error
+		if skew, err = time.ParseDuration(j.ClockSkew); err != nil {
+			return nil, fmt.Errorf("jwt: clock_skew: %w", err)
+		}
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(skew),
+	}
+	if j.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(j.Issuer))
+	}
+	if j.Audience != "" {
+		opts = append(opts, jwt.WithAudience(j.Audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	a.jwtConfig = true
+	return a, nil
+}
+
+// keyFunc picks the verification key for the token's algorithm;
+// WithValidMethods has already rejected anything not configured.
+func (a *Authenticator) keyFunc(token *jwt.Token) (any, error) {
+	if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+		return a.hmacKey, nil
+	}
+	return a.rsaKey, nil
+}
+
+// authenticate returns the principal behind r's credentials.
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		for stored, apiKey := range a.keys {
+			if subtle.ConstantTimeCompare(stored[:], sum[:]) == 1 {
+				return &Principal{Subject: apiKey.Subject, Roles: apiKey.Roles, Method: "api_key"}, nil
+			}
+		}
+		return nil, errors.New("unknown API key")
+	}
+
+	scheme, raw, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || !a.jwtConfig {
+		return nil, errors.New("missing credentials")
+	}
+	var claims tokenClaims
+	if _, err := a.parser.ParseWithClaims(strings.TrimSpace(raw), &claims, a.keyFunc); err != nil {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid token: no subject")
+	}
+	return &Principal{Subject: claims.Subject, Roles: claims.Roles, Method: "jwt"}, nil
+}
+
+// Middleware rejects unauthenticated requests with 401. CORS preflight
+// requests pass through, since browsers never send credentials on them.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, err := a.authenticate(r)
+		if err != nil {
+			challenge := `Bearer realm="task-api"`
+			if r.Header.Get("Authorization") != "" {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			problem.Write(w, r, problem.Unauthorized(err.Error()))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+// mint signs a token with the given claims; exp defaults to an hour ahead.
+func mint(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	base := jwt.MapClaims{
+		"iss": "https://auth.example.com",
+		"aud": "task-api",
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(base, k)
+		} else {
+			base[k] = v
+		}
+	}
+	token, err := jwt.NewWithClaims(method, base).SignedString(key)
+	if err != nil {
+		t.Fatalf("SignedString failed: %v", err)
+	}
+	return token
+}
+
+func TestAuthenticator(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey failed: %v", err)
+	}
+	pubFile := filepath.Join(t.TempDir(), "public.pem")
+	if err := os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := NewAuthenticator(&AuthConfig{
+		APIKeys: []APIKey{{Key: "k-123", Subject: "ci-bot", Roles: []string{"admin"}}},
+		JWT: &JWTConfig{
+			Issuer:             "https://auth.example.com",
+			Audience:           "task-api",
+			HS256Secret:        testSecret,
+			RS256PublicKeyFile: pubFile,
+			ClockSkew:          "30s",
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator failed: %v", err)
+	}
+
+	var got *Principal
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFrom(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(method string, header ...string) *httptest.ResponseRecorder {
+		got = nil
+		req := httptest.NewRequest(method, "/tasks", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	bearer := func(token string) *httptest.ResponseRecorder {
+		return do("GET", "Authorization", "Bearer "+token)
+	}
+	hs := []byte(testSecret)
+
+	// Case 1: Valid HS256 token attaches the principal and its roles
+	rec := bearer(mint(t, jwt.SigningMethodHS256, hs, jwt.MapClaims{"roles": []string{"editor"}}))
+	if rec.Code != http.StatusOK || got == nil || got.Subject != "alice" || got.Method != "jwt" || !got.HasRole("editor") {
+		t.Errorf("Expected alice with editor role, got %d %+v", rec.Code, got)
+	}
+
+	// Case 2: Valid RS256 token
+	if rec := bearer(mint(t, jwt.SigningMethodRS256, rsaKey, nil)); rec.Code != http.StatusOK || got.Subject != "alice" {
+		t.Errorf("Expected RS256 token to pass, got %d", rec.Code)
+	}
+
+	// Case 3: Valid API key
+	if rec := do("GET", APIKeyHeader, "k-123"); rec.Code != http.StatusOK || got.Subject != "ci-bot" || got.Method != "api_key" || !got.HasRole("admin") {
+		t.Errorf("Expected ci-bot via API key, got %d %+v", rec.Code, got)
+	}
+
+	// Case 4: Expiry honours the clock skew
+	if rec := bearer(mint(t, jwt.SigningMethodHS256, hs, jwt.MapClaims{"exp": time.Now().Add(-10 * time.Second).Unix()})); rec.Code != http.StatusOK {
+		t.Errorf("Expected token expired within skew to pass, got %d", rec.Code)
+	}
+
+	// Case 5: Tokens the server must reject
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rejected := map[string]string{
+		"expired":       mint(t, jwt.SigningMethodHS256, hs, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}),
+		"no exp":        mint(t, jwt.SigningMethodHS256, hs, jwt.MapClaims{"exp": nil}),
+		"no subject":    mint(t, jwt.SigningMethodHS256, hs, jwt.MapClaims{"sub": nil}),
+		"wrong issuer":  mint(t, jwt.SigningMethodHS256, hs, jwt.MapClaims{"iss": "https://evil.example.com"}),
+		"wrong aud":     mint(t, jwt.SigningMethodHS256, hs, jwt.MapClaims{"aud": "other-api"}),
+		"bad signature": mint(t, jwt.SigningMethodHS256, []byte("wrong"), nil),
+		"other rsa key": mint(t, jwt.SigningMethodRS256, otherKey, nil),
+		"alg HS512":     mint(t, jwt.SigningMethodHS512, hs, nil),
+		"garbage":       "not.a.token",
+	}
+	for name, token := range rejected {
+		rec := bearer(token)
+		if rec.Code != http.StatusUnauthorized || got != nil {
+			t.Errorf("%s: expected 401, got %d", name, rec.Code)
+		}
+		if !strings.Contains(rec.Header().Get("WWW-Authenticate"), `error="invalid_token"`) {
+			t.Errorf("%s: expected invalid_token challenge, got %q", name, rec.Header().Get("WWW-Authenticate"))
+		}
+	}
+
+	// Case 6: Unknown API key and missing credentials
+	if rec := do("GET", APIKeyHeader, "nope"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for unknown API key, got %d", rec.Code)
+	}
+	rec = do("GET")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") != `Bearer realm="task-api"` {
+		t.Errorf("Expected bare challenge for missing credentials, got %d %q", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected problem+json, got %q", ct)
+	}
+
+	// Case 7: Preflight requests pass without credentials
+	if rec := do("OPTIONS"); rec.Code != http.StatusOK {
+		t.Errorf("Expected OPTIONS to pass, got %d", rec.Code)
+	}
+}
+
+func TestNewAuthenticator_Config(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.json")
+	os.WriteFile(path, []byte(`{"api_keys":[{"key":"k","subject":"bot"}],"jwt":{"hs256_secret":"s","clock_skew":"1m"}}`), 0o600)
+
+	// Case 1: Config file loads
+	cfg, err := LoadAuthConfig(path)
+	if err != nil {
+		t.Fatalf("LoadAuthConfig failed: %v", err)
+	}
+	if _, err := NewAuthenticator(cfg); err != nil {
+		t.Errorf("NewAuthenticator failed: %v", err)
+	}
+
+	// Case 2: Invalid configs are rejected
+	bad := map[string]*AuthConfig{
+		"key without subject": {APIKeys: []APIKey{{Key: "k"}}},
+		"jwt without key":     {JWT: &JWTConfig{Issuer: "x"}},
+		"bad skew":            {JWT: &JWTConfig{HS256Secret: "s", ClockSkew: "soon"}},
+		"missing pem":         {JWT: &JWTConfig{RS256PublicKeyFile: filepath.Join(t.TempDir(), "none.pem")}},
+	}
+	for name, cfg := range bad {
+		if _, err := NewAuthenticator(cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -1,18 +1,176 @@
 package middleware
 
-import "net/http"
+import (
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
 
-func CorsMiddleware(next http.Handler) http.Handler {
+// CORSConfig is the cross-origin policy for browser clients.
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins ("https://app.example.com") and
+	// patterns where * stands for part of the host
+	// ("https://*.example.com"). A lone "*" allows every origin.
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read.
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and Authorization
+	// headers. It cannot be combined with a "*" origin.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight; 0 leaves it to
+	// the browser.
+	MaxAge time.Duration
+}
+
+// DefaultCORSConfig allows every origin to call the API without
+// credentials, with the methods and headers the task routes use.
+func DefaultCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", APIKeyHeader, IdempotencyKeyHeader, "If-Match", "If-None-Match"},
+		ExposedHeaders: []string{"ETag", "Link", "X-Total-Count", "Idempotent-Replayed"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+// CORS applies a CORSConfig. Matched origins are echoed back; requests
+// from other origins are served without CORS headers, so browsers
+// refuse to hand the response to the page.
+type CORS struct {
+	anyOrigin bool
+	origins   map[string]bool
+	patterns  []string
+	methods   map[string]bool
+	headers   map[string]bool
+	cfg       CORSConfig
+}
+
+func NewCORS(cfg CORSConfig) (*CORS, error) {
+	c := &CORS{
+		origins: map[string]bool{},
+		methods: map[string]bool{},
+		headers: map[string]bool{},
+		cfg:     cfg,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch {
+		case origin == "*":
+			c.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			if _, err := path.Match(origin, ""); err != nil {
+				return nil, errors.New("cors: bad origin pattern " + origin)
+			}
+			c.patterns = append(c.patterns, origin)
+		case origin != "":
+			c.origins[origin] = true
+		}
+	}
+	if c.anyOrigin && cfg.AllowCredentials {
+		return nil, errors.New("cors: credentials cannot be allowed for every origin; list the origins instead")
+	}
+	for _, method := range cfg.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range cfg.AllowedHeaders {
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+	return c, nil
+}
+
+// allowed reports whether origin may read responses.
+func (c *CORS) allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	if c.anyOrigin || c.origins[origin] {
+		return true
+	}
+	for _, pattern := range c.patterns {
+		if ok, _ := path.Match(pattern, origin); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// setOrigin sets the headers every allowed CORS response carries.
+func (c *CORS) setOrigin(h http.Header, origin string) {
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if c.cfg.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Middleware wraps the whole router rather than being added with
+// router.Use: mux only runs Use middleware on matched routes, and no
+// route answers OPTIONS, so preflights would never reach it.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+		origin := r.Header.Get("Origin")
+		if !c.anyOrigin {
+			// The response depends on Origin, so caches must key on it.
+			w.Header().Add("Vary", "Origin")
+		}
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			c.preflight(w, r, origin)
 			return
 		}
 
+		if c.allowed(origin) {
+			c.setOrigin(w.Header(), origin)
+			if len(c.cfg.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.cfg.ExposedHeaders, ", "))
+			}
+		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// preflight answers a CORS preflight request itself. A refused
+// preflight still gets 204, just without Access-Control-Allow-* headers.
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, origin string) {
+	h := w.Header()
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	defer w.WriteHeader(http.StatusNoContent)
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !c.allowed(origin) || !c.methods[method] {
+		return
+	}
+	var requested []string
+	for _, list := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(list, ",") {
+			if header = strings.TrimSpace(header); header == "" {
+				continue
+			}
+			if !c.headers[http.CanonicalHeaderKey(header)] {
+				return
+			}
+			requested = append(requested, header)
+		}
+	}
+
+	c.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(c.cfg.AllowedMethods, ", "))
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if c.cfg.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.cfg.MaxAge.Seconds())))
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCORS(t *testing.T) {
+	cfg := DefaultCORSConfig()
+	cfg.AllowedOrigins = []string{"https://app.example.com", "https://*.preview.example.com"}
+	cfg.AllowCredentials = true
+	cfg.MaxAge = time.Hour
+	cors, err := NewCORS(cfg)
+	if err != nil {
+		t.Fatalf("NewCORS failed: %v", err)
+	}
+
+	var reached bool
+	handler := cors.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(method, origin string, header ...string) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(method, "/tasks", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Case 1: Same-origin requests pass through untouched apart from Vary
+	rec := do("GET", "")
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("Expected plain pass-through with Vary: Origin, got %v", rec.Header())
+	}
+
+	// Case 2: A listed origin is echoed with credentials and exposed headers
+	rec = do("GET", "https://app.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected origin echoed, got %q", got)
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Errorf("Expected credentials and exposed headers, got %v", rec.Header())
+	}
+
+	// Case 3: Patterns match subdomains only
+	for origin, want := range map[string]bool{
+		"https://pr-42.preview.example.com": true,
+		"https://PR-7.Preview.Example.com":  true,
+		"https://preview.example.com":       false,
+		"http://pr-42.preview.example.com":  false,
+		"https://evil.com":                  false,
+	} {
+		rec := do("GET", origin)
+		if got := rec.Header().Get("Access-Control-Allow-Origin") != ""; got != want {
+			t.Errorf("%s: expected allowed=%v, got %v", origin, want, got)
+		}
+		if !reached {
+			t.Errorf("%s: actual requests must reach the handler", origin)
+		}
+	}
+
+	// Case 4: An allowed preflight is answered without the handler
+	rec = do("OPTIONS", "https://app.example.com",
+		"Access-Control-Request-Method", "PATCH",
+		"Access-Control-Request-Headers", "content-type, if-match")
+	if reached || rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 without the handler, got %d (reached %v)", rec.Code, reached)
+	}
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Methods":     "GET, POST, PUT, PATCH, DELETE",
+		"Access-Control-Allow-Headers":     "content-type, if-match",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "3600",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 3 {
+		t.Errorf("Expected Vary on Origin and both request headers, got %v", vary)
+	}
+
+	// Case 5: Refused preflights get no Allow headers
+	refused := map[string][]string{
+		"origin": {"https://evil.com", "Access-Control-Request-Method", "GET"},
+		"method": {"https://app.example.com", "Access-Control-Request-Method", "TRACE"},
+		"header": {"https://app.example.com", "Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "X-Debug"},
+	}
+	for name, args := range refused {
+		rec := do("OPTIONS", args[0], args[1:]...)
+		if reached || rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%s: expected refused preflight, got %d %v", name, rec.Code, rec.Header())
+		}
+	}
+
+	// Case 6: OPTIONS without Access-Control-Request-Method is not a preflight
+	if do("OPTIONS", "https://app.example.com"); !reached {
+		t.Error("Expected a plain OPTIONS request to reach the handler")
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	cors, err := NewCORS(DefaultCORSConfig())
+	if err != nil {
+		t.Fatalf("NewCORS failed: %v", err)
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	handler := cors.Middleware(router)
+
+	// Case 1: Every origin gets "*" and the response does not vary
+	req := httptest.NewRequest("GET", "/tasks", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Vary") != "" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("Expected wildcard origin without Vary, got %v", rec.Header())
+	}
+
+	// Case 2: Preflights succeed although no route accepts OPTIONS
+	req = httptest.NewRequest("OPTIONS", "/tasks", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("Expected preflight through the router to pass, got %d %v", rec.Code, rec.Header())
+	}
+}
+
+func TestNewCORS_InvalidConfig(t *testing.T) {
+	for name, cfg := range map[string]CORSConfig{
+		"wildcard with credentials": {AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		"bad pattern":               {AllowedOrigins: []string{"https://*[.example.com"}},
+	} {
+		if _, err := NewCORS(cfg); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
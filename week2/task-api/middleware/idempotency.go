@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"problem"
+	"sync"
+	"time"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultIdempotencyTTL is how long a stored response is replayed.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+type idempotencyEntry struct {
+	fingerprint [32]byte
+	done        bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// IdempotencyCache remembers the first response for each Idempotency-Key
+// so a retried request is answered from the cache instead of running
+// again. A repeat with a different body is rejected with 422, and a
+// repeat while the first request is still running gets 409.
+type IdempotencyCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*idempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewIdempotencyCache(ttl time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		ttl:     ttl,
+		entries: map[string]*idempotencyEntry{},
+		now:     time.Now,
+	}
+}
+
+// sweep drops expired entries, at most once a minute.
+func (c *IdempotencyCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < time.Minute {
+		return
+	}
+	c.lastSweep = now
+	for key, entry := range c.entries {
+		if entry.done && now.After(entry.expires) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// captureWriter passes the response through while keeping a copy.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	cw.status = code
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+// Middleware applies the cache to requests that carry an Idempotency-Key;
+// requests without one pass straight through.
+func (c *IdempotencyCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > 255 {
+			problem.Write(w, r, problem.BadRequest("Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			problem.Write(w, r, problem.BadRequest("could not read request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		// Keys are per caller, so two users picking the same key never
+		// see each other's responses.
+		if p, ok := PrincipalFrom(r.Context()); ok {
+			key = p.Subject + "\x00" + key
+		}
+		fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+
+		c.mu.Lock()
+		now := c.now()
+		c.sweep(now)
+		entry, ok := c.entries[key]
+		if ok && entry.done && now.After(entry.expires) {
+			ok = false
+		}
+		switch {
+		case ok && entry.fingerprint != fingerprint:
+			c.mu.Unlock()
+			problem.Write(w, r, problem.Unprocessable("Idempotency-Key was already used with a different request", nil))
+			return
+		case ok && !entry.done:
+			c.mu.Unlock()
+			problem.Write(w, r, problem.Conflict("a request with this Idempotency-Key is still being processed"))
+			return
+		case ok:
+			c.mu.Unlock()
+			replay(w, entry)
+			return
+		}
+		entry = &idempotencyEntry{fingerprint: fingerprint}
+		c.entries[key] = entry
+		c.mu.Unlock()
+
+		cw := &captureWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// Server errors are not remembered so the client's retry can
+		// actually run again.
+		if cw.status >= 500 || cw.status == 0 {
+			delete(c.entries, key)
+			return
+		}
+		entry.done = true
+		entry.status = cw.status
+		entry.header = w.Header().Clone()
+		// The retry keeps its own request ID so its access log record and
+		// trace still line up.
+		entry.header.Del(problem.RequestIDHeader)
+		entry.body = cw.body.Bytes()
+		entry.expires = c.now().Add(c.ttl)
+	})
+}
+
+func replay(w http.ResponseWriter, entry *idempotencyEntry) {
+	for name, values := range entry.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(entry.status)
+	w.Write(entry.body)
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"problem"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	var calls atomic.Int32
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("ETag", `"`+strconv.Itoa(int(n))+`"`)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":` + strconv.Itoa(int(n)) + `}`))
+	})
+	cache := NewIdempotencyCache(time.Hour)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+	handler := cache.Middleware(next)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Case 1: Repeat with the same key and body replays the first response
+	first := post("abc", `{"description":"Buy milk"}`)
+	again := post("abc", `{"description":"Buy milk"}`)
+	if calls.Load() != 1 {
+		t.Fatalf("Expected handler to run once, ran %d times", calls.Load())
+	}
+	if again.Code != first.Code || again.Body.String() != first.Body.String() || again.Header().Get("ETag") != `"1"` {
+		t.Errorf("Replay differs: %d %q vs %d %q", again.Code, again.Body, first.Code, first.Body)
+	}
+	if again.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("Replay not marked with Idempotent-Replayed")
+	}
+
+	// Case 2: Same key, different body is rejected
+	if rec := post("abc", `{"description":"Buy bread"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for reused key, got %d", rec.Code)
+	}
+
+	// Case 3: Requests without a key always run
+	post("", `{}`)
+	post("", `{}`)
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls.Load())
+	}
+
+	// Case 4: After the TTL the key can be used again
+	now = now.Add(2 * time.Hour)
+	if rec := post("abc", `{"description":"Buy bread"}`); rec.Code != http.StatusCreated || calls.Load() != 4 {
+		t.Errorf("Expected key reuse after TTL, got %d with %d calls", rec.Code, calls.Load())
+	}
+}
+
+func TestIdempotencyCache_ReplayKeepsRequestID(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := AccessLog(logger)(NewIdempotencyCache(time.Hour).Middleware(next))
+
+	for _, id := range []string{"first-request", "retried-request"} {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "abc")
+		req.Header.Set(problem.RequestIDHeader, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get(problem.RequestIDHeader); got != id {
+			t.Errorf("Expected X-Request-ID %q, got %q", id, got)
+		}
+	}
+}
+
+func TestIdempotencyCache_InFlightAndServerErrors(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var fail atomic.Bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get(IdempotencyKeyHeader) != "slow" {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := NewIdempotencyCache(time.Hour).Middleware(next)
+
+	post := func(key string) int {
+		req := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Case 1: A duplicate while the first is running gets 409
+	done := make(chan int)
+	go func() { done <- post("slow") }()
+	<-started
+	if code := post("slow"); code != http.StatusConflict {
+		t.Errorf("Expected 409 while in flight, got %d", code)
+	}
+	close(release)
+	if code := <-done; code != http.StatusCreated {
+		t.Errorf("Expected first request to finish with 201, got %d", code)
+	}
+
+	// Case 2: 5xx responses are not stored, so the retry runs again
+	fail.Store(true)
+	post("flaky")
+	post("flaky")
+	fail.Store(false)
+	if code := post("flaky"); code != http.StatusCreated {
+		t.Errorf("Expected retry after server error to run, got %d", code)
+	}
+}
@@ -1,15 +1,175 @@
 package middleware
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"problem"
+	"strings"
+	"task-api/tracing"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
-func LoggingMiddleware(next http.Handler) http.Handler {
+// NewLogger returns a slog.Logger writing to w in format ("json" or
+// "text") that drops records below level.
+func NewLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want json or text)", format)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the request-scoped logger stored by AccessLog, which
+// tags every record with the request ID, or slog.Default().
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// statusWriter records the status code and body size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if sw.status == 0 {
+		sw.status = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	if sw.status == 0 {
+		sw.status = http.StatusOK
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// routeKey holds where RouteTemplate leaves the matched route for the
+// access log and tracing, which run outside the router and cannot see
+// mux's vars.
+type routeKey struct{}
+
+// withRouteSlot returns ctx with somewhere for RouteTemplate to write,
+// reusing the slot an outer middleware already added.
+func withRouteSlot(ctx context.Context) (context.Context, *string) {
+	if slot, ok := ctx.Value(routeKey{}).(*string); ok {
+		return ctx, slot
+	}
+	slot := new(string)
+	return context.WithValue(ctx, routeKey{}, slot), slot
+}
+
+// RouteTemplate records the path template of the matched mux route (e.g.
+// /tasks/{id}) for AccessLog and Tracing. Install it with router.Use.
+func RouteTemplate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+		if slot, ok := r.Context().Value(routeKey{}).(*string); ok {
+			if route := mux.CurrentRoute(r); route != nil {
+				*slot, _ = route.GetPathTemplate()
+			}
+		}
 		next.ServeHTTP(w, r)
-		log.Printf("%s %s %v", r.Method, r.URL.Path, time.Since(start))
 	})
 }
+
+// validRequestID accepts client request IDs that are safe to echo and
+// log: up to 128 printable ASCII characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLog returns middleware that gives every request an ID and logs
+// one record per response. The ID is taken from the client's
+// X-Request-ID when it is sane and generated otherwise; it is echoed in
+// the response, stored for problem.RequestID and attached to the
+// request-scoped Logger, along with the trace and span IDs when Tracing
+// runs outside it. Wrap the whole router so 404s, 405s and
+// preflights are logged too, and install RouteTemplate inside it.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(problem.RequestIDHeader)
+			if !validRequestID(id) {
+				id = problem.NewRequestID()
+			}
+			w.Header().Set(problem.RequestIDHeader, id)
+
+			reqLogger := logger.With("request_id", id)
+			if span := tracing.SpanFromContext(r.Context()); span != nil {
+				sc := span.SpanContext()
+				reqLogger = reqLogger.With("trace_id", sc.TraceID.String(), "span_id", sc.SpanID.String())
+			}
+			ctx, route := withRouteSlot(problem.WithRequestID(r.Context(), id))
+			ctx = WithLogger(ctx, reqLogger)
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			if sw.status >= 500 {
+				level = slog.LevelError
+			}
+			reqLogger.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("route", *route),
+				slog.Int("status", sw.status),
+				slog.Int64("bytes", sw.bytes),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("client_ip", clientIP(r)),
+				slog.String("user_agent", r.UserAgent()),
+			)
+		})
+	}
+}
+
+// clientIP is the address of the connection's peer. Forwarding headers
+// are ignored because nothing says which proxies to trust.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return strings.TrimSpace(r.RemoteAddr)
+	}
+	return host
+}
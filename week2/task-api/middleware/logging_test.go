@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"problem"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "json", slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(RouteTemplate)
+	router.HandleFunc("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		Logger(r.Context()).Info("loading task", "id", mux.Vars(r)["id"])
+		w.Write([]byte("hello"))
+	})
+	router.HandleFunc("/boom", func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.Conflict("boom"))
+	})
+	handler := AccessLog(logger)(router)
+
+	do := func(path, requestID string) (*httptest.ResponseRecorder, []map[string]any) {
+		buf.Reset()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", "test-agent")
+		if requestID != "" {
+			req.Header.Set(problem.RequestIDHeader, requestID)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("Log line is not JSON: %q", line)
+			}
+			records = append(records, record)
+		}
+		return rec, records
+	}
+
+	// Case 1: The access record carries status, size, route and client
+	rec, records := do("/tasks/42", "abc-123")
+	if len(records) != 2 {
+		t.Fatalf("Expected handler and access records, got %v", records)
+	}
+	access := records[1]
+	for key, want := range map[string]any{
+		"msg":        "request",
+		"level":      "INFO",
+		"method":     "GET",
+		"path":       "/tasks/42",
+		"route":      "/tasks/{id}",
+		"status":     float64(200),
+		"bytes":      float64(5),
+		"client_ip":  "192.0.2.1",
+		"user_agent": "test-agent",
+		"request_id": "abc-123",
+	} {
+		if access[key] != want {
+			t.Errorf("%s: expected %v, got %v", key, want, access[key])
+		}
+	}
+	if _, ok := access["duration"]; !ok {
+		t.Error("Expected a duration")
+	}
+
+	// Case 2: Handler records share the request ID, which is echoed
+	if records[0]["msg"] != "loading task" || records[0]["request_id"] != "abc-123" {
+		t.Errorf("Handler record lacks the request ID: %v", records[0])
+	}
+	if rec.Header().Get(problem.RequestIDHeader) != "abc-123" {
+		t.Errorf("Expected request ID echoed, got %q", rec.Header().Get(problem.RequestIDHeader))
+	}
+
+	// Case 3: Missing or unsafe IDs are replaced, and problems reuse them
+	rec, records = do("/boom", "bad id\nwith newline")
+	id := rec.Header().Get(problem.RequestIDHeader)
+	var p problem.Problem
+	json.NewDecoder(rec.Body).Decode(&p)
+	if id == "" || strings.Contains(id, " ") || p.RequestID != id || records[0]["request_id"] != id {
+		t.Errorf("Expected one generated ID, got header %q, problem %q, log %v", id, p.RequestID, records[0]["request_id"])
+	}
+	if records[0]["status"] != float64(http.StatusConflict) {
+		t.Errorf("Expected status 409 logged, got %v", records[0]["status"])
+	}
+
+	// Case 4: Unmatched routes are logged without a template
+	_, records = do("/nowhere", "")
+	if records[0]["status"] != float64(http.StatusNotFound) || records[0]["route"] != "" {
+		t.Errorf("Unexpected 404 record: %v", records[0])
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "text", slog.LevelWarn)
+	if err != nil {
+		t.Fatalf("NewLogger failed: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown", "key", "value")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "level=WARN msg=shown key=value") {
+		t.Errorf("Unexpected text output: %q", out)
+	}
+	if _, err := NewLogger(&buf, "xml", slog.LevelInfo); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"task-api/storage"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics collects Prometheus metrics for the API in its own registry,
+// so tests can create as many as they like.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+	storage  *prometheus.HistogramVec
+}
+
+// NewMetrics registers the task-api metrics together with the Go runtime
+// and process collectors.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "taskapi",
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by method, route template and status.",
+		}, []string{"method", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "taskapi",
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to handle HTTP requests, by method, route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "taskapi",
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being handled.",
+		}),
+		storage: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "taskapi",
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Time spent in the storage backend, by operation (load, save, ...) and result.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"operation", "result"}),
+	}
+	m.registry.MustRegister(
+		m.requests, m.latency, m.inFlight, m.storage,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Middleware counts and times requests by method, route template and
+// status. Install it with router.Use; requests that match no route never
+// reach router middleware and are not counted.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if current := mux.CurrentRoute(r); current != nil {
+			route, _ = current.GetPathTemplate()
+		}
+
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		status := strconv.Itoa(sw.status)
+		m.requests.WithLabelValues(r.Method, route, status).Inc()
+		m.latency.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveStorage records one storage operation; it fits
+// storage.ObservedStore.Observe.
+func (m *Metrics) ObserveStorage(_ context.Context, op string, d time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.storage.WithLabelValues(op, result).Observe(d.Seconds())
+}
+
+// WatchTasks exports task counts from store, read at every scrape.
+func (m *Metrics) WatchTasks(store storage.TaskStore) {
+	m.registry.MustRegister(&taskCollector{store: store})
+}
+
+var (
+	tasksDesc = prometheus.NewDesc("taskapi_tasks",
+		"Tasks not in the trash.", nil, nil)
+	tasksOpenDesc = prometheus.NewDesc("taskapi_tasks_open",
+		"Open tasks not in the trash.", nil, nil)
+	tasksCompletedDesc = prometheus.NewDesc("taskapi_tasks_completed",
+		"Completed tasks not in the trash.", nil, nil)
+	tasksDeletedDesc = prometheus.NewDesc("taskapi_tasks_deleted",
+		"Tasks in the trash.", nil, nil)
+)
+
+// taskCollector counts tasks on demand rather than tracking every
+// change, so the numbers cannot drift from the store.
+type taskCollector struct {
+	store storage.TaskStore
+}
+
+func (c *taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tasksDesc
+	ch <- tasksOpenDesc
+	ch <- tasksCompletedDesc
+	ch <- tasksDeletedDesc
+}
+
+func (c *taskCollector) Collect(ch chan<- prometheus.Metric) {
+	tasks, err := c.store.List()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(tasksDesc, err)
+		return
+	}
+	var open, completed, deleted int
+	for _, task := range tasks {
+		switch {
+		case task.Deleted():
+			deleted++
+		case task.Completed:
+			completed++
+		default:
+			open++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(open+completed))
+	ch <- prometheus.MustNewConstMetric(tasksOpenDesc, prometheus.GaugeValue, float64(open))
+	ch <- prometheus.MustNewConstMetric(tasksCompletedDesc, prometheus.GaugeValue, float64(completed))
+	ch <- prometheus.MustNewConstMetric(tasksDeletedDesc, prometheus.GaugeValue, float64(deleted))
+}
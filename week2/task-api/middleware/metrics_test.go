@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-api/models"
+	"task-api/storage"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	backend := storage.NewMemoryStore()
+	backend.Create(models.NewTask(0, "Already stored"))
+	store, err := storage.NewCachedStore(&storage.ObservedStore{TaskStore: backend, Observe: metrics.ObserveStorage, Names: storage.BackendNames}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewCachedStore failed: %v", err)
+	}
+	metrics.WatchTasks(store)
+
+	router := mux.NewRouter()
+	router.Use(metrics.Middleware)
+	router.HandleFunc("/tasks/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "404" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	}).Methods("GET")
+
+	for _, path := range []string{"/tasks/1", "/tasks/2", "/tasks/404"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	done := models.NewTask(0, "Done")
+	done.Complete()
+	trashed := models.NewTask(0, "Trashed")
+	trashed.Trash()
+	store.Create(done)
+	store.Create(trashed)
+	store.Create(models.NewTask(0, "Open"))
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+			t.Fatalf("Unexpected scrape response: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(rec.Body)
+		return string(body)
+	}
+	body := scrape()
+
+	// Case 1: Requests are counted and timed by route template and status
+	for _, want := range []string{
+		`taskapi_http_requests_total{method="GET",route="/tasks/{id:[0-9]+}",status="200"} 2`,
+		`taskapi_http_requests_total{method="GET",route="/tasks/{id:[0-9]+}",status="404"} 1`,
+		`taskapi_http_request_duration_seconds_count{method="GET",route="/tasks/{id:[0-9]+}",status="200"} 2`,
+		`taskapi_http_requests_in_flight 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q in:\n%s", want, body)
+		}
+	}
+
+	// Case 2: The startup load and the flush are timed
+	for _, want := range []string{
+		`taskapi_storage_operation_duration_seconds_count{operation="load",result="ok"} 1`,
+		`taskapi_storage_operation_duration_seconds_count{operation="save",result="ok"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Missing %q", want)
+		}
+	}
+
+	// Case 3: Task counts reflect the store at scrape time
+	for _, want := range []string{
+		"taskapi_tasks 3",
+		"taskapi_tasks_open 2",
+		"taskapi_tasks_completed 1",
+		"taskapi_tasks_deleted 1",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("Missing %q", want)
+		}
+	}
+	store.Delete(done.ID)
+	if body := scrape(); !strings.Contains(body, "taskapi_tasks_completed 0\n") {
+		t.Error("Task counts did not follow the store")
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"task-api/tracing"
+)
+
+// Tracing returns middleware that runs each request in a server span.
+// A valid traceparent header makes the span part of the caller's trace
+// (and follows its sampling decision); otherwise a new trace starts.
+// Install it outside AccessLog so log records carry the trace ID, and
+// RouteTemplate inside the router to name spans after the route.
+func Tracing(tracer *tracing.Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if sc, err := tracing.ParseTraceparent(r.Header.Get(tracing.TraceparentHeader)); err == nil {
+				ctx = tracing.ContextWithRemote(ctx, sc)
+			}
+			ctx, route := withRouteSlot(ctx)
+			ctx, span := tracer.Start(ctx, r.Method, tracing.WithKind(tracing.KindServer))
+			defer span.End()
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+			span.SetAttribute("client.address", clientIP(r))
+			if ua := r.UserAgent(); ua != "" {
+				span.SetAttribute("user_agent.original", ua)
+			}
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+
+			if *route != "" {
+				span.SetName(r.Method + " " + *route)
+				span.SetAttribute("http.route", *route)
+			}
+			span.SetAttribute("http.response.status_code", sw.status)
+			if sw.status >= http.StatusInternalServerError {
+				span.SetError(httpStatusError(sw.status))
+			}
+		})
+	}
+}
+
+// httpStatusError describes a 5xx response for a span's status.
+type httpStatusError int
+
+func (e httpStatusError) Error() string {
+	return http.StatusText(int(e))
+}
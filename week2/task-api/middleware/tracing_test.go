@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-api/tracing"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTracing(t *testing.T) {
+	var spans, logs bytes.Buffer
+	tracer := tracing.NewTracer(tracing.NewWriterExporter(&spans), nil)
+	logger, _ := NewLogger(&logs, "json", slog.LevelInfo)
+
+	router := mux.NewRouter()
+	router.Use(RouteTemplate)
+	router.HandleFunc("/tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracer.Start(r.Context(), "load task")
+		span.End()
+		if mux.Vars(r)["id"] == "500" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	handler := Tracing(tracer)(AccessLog(logger)(router))
+
+	do := func(path, traceparent string) []tracing.SpanData {
+		spans.Reset()
+		logs.Reset()
+		req := httptest.NewRequest("GET", path, nil)
+		if traceparent != "" {
+			req.Header.Set(tracing.TraceparentHeader, traceparent)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		var out []tracing.SpanData
+		for _, line := range strings.Split(strings.TrimSpace(spans.String()), "\n") {
+			var span tracing.SpanData
+			json.Unmarshal([]byte(line), &span)
+			out = append(out, span)
+		}
+		return out
+	}
+
+	// Case 1: The caller's trace is continued and the span named after the route
+	const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	got := do("/tasks/7", parent)
+	if len(got) != 2 {
+		t.Fatalf("Expected handler and server spans, got %+v", got)
+	}
+	server := got[1]
+	if server.Name != "GET /tasks/{id}" || server.Kind != tracing.KindServer ||
+		server.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected server span: %+v", server)
+	}
+	if server.Attributes["http.route"] != "/tasks/{id}" || server.Attributes["http.response.status_code"] != float64(200) ||
+		server.Attributes["url.path"] != "/tasks/7" {
+		t.Errorf("Unexpected attributes: %v", server.Attributes)
+	}
+	if got[0].ParentSpanID != server.SpanID {
+		t.Errorf("Handler span is not a child of the server span: %+v", got[0])
+	}
+
+	// Case 2: Log records carry the trace
+	var record map[string]any
+	json.Unmarshal(logs.Bytes(), &record)
+	if record["trace_id"] != server.TraceID || record["span_id"] != server.SpanID {
+		t.Errorf("Log record lacks the trace: %v", record)
+	}
+
+	// Case 3: Without a usable header a new trace starts; 5xx marks an error
+	got = do("/tasks/500", "not-a-traceparent")
+	if server := got[1]; server.TraceID == "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID != "" || server.Status != "error" {
+		t.Errorf("Unexpected server span: %+v", server)
+	}
+
+	// Case 4: An unsampled caller gets no exported spans
+	do("/tasks/7", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	if spans.Len() != 0 {
+		t.Errorf("Unsampled request was exported: %s", spans.String())
+	}
+}
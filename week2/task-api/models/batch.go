@@ -0,0 +1,17 @@
+package models
+
+import "encoding/json"
+
+// BatchRequest is the body of POST /tasks:batch. One request may carry
+// up to 1000 operations.
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" validate:"required,max=1000"`
+}
+
+// BatchOperation is one step of a batch. Task holds a TaskData body for
+// "create" and a TaskPatch body for "update"; the other ops need only ID.
+type BatchOperation struct {
+	Op   string          `json:"op" validate:"required,oneof=create update complete delete"`
+	ID   int             `json:"id"`
+	Task json.RawMessage `json:"task"`
+}
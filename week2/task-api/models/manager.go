@@ -1,26 +1,167 @@
 package models
 
-import "strings"
+import (
+	"strings"
+	"sync"
+	"task-api/search"
+)
 
 // TaskManager Model with helper methods and Constructor
 type TaskManager struct {
 	Tasks  []*Task
 	NextID int
+
+	// byID finds tasks without scanning Tasks; every method that adds,
+	// swaps or removes a task keeps it in step.
+	byID map[int]*Task
+
+	// index is built on the first Search and then kept up to date by
+	// every method that changes a task's text. indexMu only guards the
+	// lazy build, which can happen under a store's read lock.
+	indexMu sync.Mutex
+	index   *search.Index
+}
+
+// SearchResult is one task matched by TaskManager.Search. Snippet is
+// left empty until TaskQuery.ApplyRanked picks the page to return.
+type SearchResult struct {
+	*Task
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet,omitempty"`
+
+	hit search.Hit
+}
+
+// searchFields are the indexed parts of a task, most important first.
+var searchFields = []search.Field{
+	{Name: "description", Weight: 3},
+	{Name: "tags", Weight: 2},
+	{Name: "notes", Weight: 1},
+}
+
+// searchTexts are a task's indexed fields, in searchFields order.
+func searchTexts(task *Task) []string {
+	return []string{task.Description, strings.Join(task.Tags, " "), task.Notes}
+}
+
+func (tm *TaskManager) indexTask(task *Task) {
+	if tm.index != nil {
+		tm.index.Add(task.ID, searchTexts(task)...)
+	}
+}
+
+func (tm *TaskManager) unindexTask(id int) {
+	if tm.index != nil {
+		tm.index.Remove(id)
+	}
 }
 
 func NewTaskManager() *TaskManager {
 	return &TaskManager{
 		Tasks:  []*Task{},
 		NextID: 1,
+		byID:   map[int]*Task{},
 	}
 }
 
+// Load replaces the managed tasks and moves NextID past the highest ID.
+func (tm *TaskManager) Load(tasks []*Task) {
+	tm.Tasks = tasks
+	tm.index = nil
+	tm.NextID = 1
+	tm.byID = make(map[int]*Task, len(tasks))
+	for _, task := range tasks {
+		tm.byID[task.ID] = task
+		if task.ID >= tm.NextID {
+			tm.NextID = task.ID + 1
+		}
+	}
+}
+
+// Clone returns a deep copy, so changes to it can be thrown away.
+func (tm *TaskManager) Clone() *TaskManager {
+	clone := &TaskManager{
+		Tasks:  make([]*Task, 0, len(tm.Tasks)),
+		NextID: tm.NextID,
+		byID:   make(map[int]*Task, len(tm.Tasks)),
+	}
+	for _, task := range tm.Tasks {
+		task = task.Clone()
+		clone.Tasks = append(clone.Tasks, task)
+		clone.byID[task.ID] = task
+	}
+	return clone
+}
+
 func (tm *TaskManager) Add(description string) *Task {
 	task := NewTask(tm.NextID, description)
 	tm.Tasks = append(tm.Tasks, task)
+	tm.track(task)
+	tm.NextID++
+	tm.indexTask(task)
 	return task
 }
 
+// Insert appends an already built task, assigning the next ID when it has none.
+func (tm *TaskManager) Insert(task *Task) *Task {
+	if task.ID == 0 {
+		task.ID = tm.NextID
+	}
+	if task.Version == 0 {
+		task.Version = 1
+	}
+	if task.ID >= tm.NextID {
+		tm.NextID = task.ID + 1
+	}
+	tm.Tasks = append(tm.Tasks, task)
+	tm.track(task)
+	tm.indexTask(task)
+	return task
+}
+
+func (tm *TaskManager) track(task *Task) {
+	if tm.byID == nil {
+		tm.byID = map[int]*Task{}
+	}
+	tm.byID[task.ID] = task
+}
+
+func (tm *TaskManager) Get(id int) *Task {
+	return tm.byID[id]
+}
+
+// Replace swaps the stored task with the same ID for task.
+func (tm *TaskManager) Replace(task *Task) bool {
+	for idx, t := range tm.Tasks {
+		if t.ID == task.ID {
+			tm.Tasks[idx] = task
+			tm.track(task)
+			tm.indexTask(task)
+			return true
+		}
+	}
+	return false
+}
+
+// Modify applies change to a copy of the task and, when change succeeds,
+// stores the copy with its Version bumped. The stored task is untouched
+// if change returns an error.
+func (tm *TaskManager) Modify(id int, change func(task *Task) error) (*Task, error) {
+	current := tm.Get(id)
+	if current == nil {
+		return nil, TaskNotFoundError{ID: id}
+	}
+
+	task := current.Clone()
+	if err := change(task); err != nil {
+		return nil, err
+	}
+	task.ID = id
+	task.Version = current.Version + 1
+	tm.Replace(task)
+	return task, nil
+}
+
 func (tm *TaskManager) List() []*Task {
 	return tm.Tasks
 }
@@ -39,23 +180,49 @@ func (tm *TaskManager) Delete(id int) bool {
 	for idx, task := range tm.Tasks {
 		if task.ID == id {
 			tm.Tasks = append(tm.Tasks[:idx], tm.Tasks[idx+1:]...)
+			delete(tm.byID, id)
+			tm.unindexTask(id)
 			return true
 		}
 	}
 	return false
 }
 
-func (tm *TaskManager) Search(query string) []*Task {
-	results := []*Task{}
+// Search runs a full-text query (see search.Parse) over descriptions,
+// tags and notes and returns the matches best first, without snippets.
+// An empty query matches every task, in stored order.
+func (tm *TaskManager) Search(query string) ([]SearchResult, error) {
+	q, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if q.Empty() {
+		results := make([]SearchResult, 0, len(tm.Tasks))
+		for _, task := range tm.Tasks {
+			results = append(results, SearchResult{Task: task})
+		}
+		return results, nil
+	}
 
-	for _, task := range tm.Tasks {
-		if strings.Contains(strings.ToLower(task.Description), query) {
-			results = append(results, task)
+	hits := tm.searchIndex().Search(q)
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if task := tm.byID[hit.ID]; task != nil {
+			results = append(results, SearchResult{Task: task, Score: hit.Score, hit: hit})
 		}
 	}
-	return results
+	return results, nil
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+func (tm *TaskManager) searchIndex() *search.Index {
+	tm.indexMu.Lock()
+	defer tm.indexMu.Unlock()
+	if tm.index == nil {
+		index := search.NewIndex(searchFields...)
+		for _, task := range tm.Tasks {
+			index.Add(task.ID, searchTexts(task)...)
+		}
+		tm.index = index
+	}
+	return tm.index
 }
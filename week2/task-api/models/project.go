@@ -0,0 +1,130 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProjectRole is what a member may do in a project.
+type ProjectRole string
+
+const (
+	RoleOwner  ProjectRole = "owner"  // edit tasks and manage members
+	RoleEditor ProjectRole = "editor" // edit tasks
+	RoleViewer ProjectRole = "viewer" // read only
+)
+
+// ProjectRoles lists the roles from least to most privileged.
+var ProjectRoles = []ProjectRole{RoleViewer, RoleEditor, RoleOwner}
+
+func (r ProjectRole) rank() int {
+	for i, known := range ProjectRoles {
+		if r == known {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Allows reports whether r grants everything need does.
+func (r ProjectRole) Allows(need ProjectRole) bool {
+	return r.rank() > 0 && r.rank() >= need.rank()
+}
+
+// Member is one user's membership of a project.
+type Member struct {
+	Subject string      `json:"subject"`
+	Role    ProjectRole `json:"role"`
+}
+
+// Project groups tasks that a team shares.
+type Project struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Members   []Member  `json:"members"`
+	CreatedAt time.Time `json:"created_at"`
+	Version   int       `json:"version"`
+}
+
+func NewProject(id int, name string) *Project {
+	return &Project{
+		ID:        id,
+		Name:      name,
+		Members:   []Member{},
+		CreatedAt: time.Now(),
+		Version:   1,
+	}
+}
+
+// Clone returns a deep copy that shares no slices with p.
+func (p *Project) Clone() *Project {
+	c := *p
+	c.Members = append([]Member{}, p.Members...)
+	return &c
+}
+
+// Role returns subject's role, or "" when subject is not a member.
+func (p *Project) Role(subject string) ProjectRole {
+	for _, m := range p.Members {
+		if m.Subject == subject {
+			return m.Role
+		}
+	}
+	return ""
+}
+
+// SetMember adds subject with role or changes their existing role.
+func (p *Project) SetMember(subject string, role ProjectRole) {
+	for i := range p.Members {
+		if p.Members[i].Subject == subject {
+			p.Members[i].Role = role
+			return
+		}
+	}
+	p.Members = append(p.Members, Member{Subject: subject, Role: role})
+}
+
+// RemoveMember drops subject and reports whether they were a member.
+func (p *Project) RemoveMember(subject string) bool {
+	for i, m := range p.Members {
+		if m.Subject == subject {
+			p.Members = append(p.Members[:i], p.Members[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Owners counts the members with the owner role.
+func (p *Project) Owners() int {
+	n := 0
+	for _, m := range p.Members {
+		if m.Role == RoleOwner {
+			n++
+		}
+	}
+	return n
+}
+
+// ProjectData is the body of POST /projects.
+type ProjectData struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+func (d *ProjectData) Normalize() {
+	d.Name = strings.TrimSpace(d.Name)
+}
+
+// MemberData is the body of PUT /projects/{id}/members/{subject}.
+type MemberData struct {
+	Role ProjectRole `json:"role" validate:"required,oneof=owner editor viewer"`
+}
+
+type ProjectNotFoundError struct {
+	ID int
+}
+
+func (e ProjectNotFoundError) Error() string {
+	return fmt.Sprintf("project with ID %d not found", e.ID)
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SortFields are the accepted values of TaskQuery.Sort; a leading "-"
+// reverses the order.
+var SortFields = []string{"id", "created_at", "completed_at", "due_at", "priority", "deleted_at"}
+
+// TaskQuery filters, orders and pages a list of tasks. Deleted tasks are
+// left out unless IncludeDeleted is set.
+type TaskQuery struct {
+	Owner          string // "" matches every owner
+	ProjectID      int    // 0 matches every project
+	Assignee       string
+	Completed      *bool
+	IncludeDeleted bool
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	Priority       Priority
+	Tag            string
+	DueAfter       *time.Time
+	DueBefore      *time.Time
+	Sort           string
+	Offset         int
+	Limit          int // 0 means no limit
+	// Where is an extra predicate every task must satisfy, such as a
+	// parsed filter expression.
+	Where func(task *Task) bool
+}
+
+// Validate reports an unknown sort field.
+func (q TaskQuery) Validate() error {
+	field := q.Sort
+	if len(field) > 0 && field[0] == '-' {
+		field = field[1:]
+	}
+	if field == "" {
+		return nil
+	}
+	for _, f := range SortFields {
+		if f == field {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown sort field %q", q.Sort)
+}
+
+func (q TaskQuery) matches(task *Task) bool {
+	if task.Deleted() && !q.IncludeDeleted {
+		return false
+	}
+	if q.Owner != "" && task.Owner != q.Owner {
+		return false
+	}
+	if q.ProjectID != 0 && task.ProjectID != q.ProjectID {
+		return false
+	}
+	if q.Assignee != "" && task.Assignee != q.Assignee {
+		return false
+	}
+	if q.Where != nil && !q.Where(task) {
+		return false
+	}
+	if q.Completed != nil && task.Completed != *q.Completed {
+		return false
+	}
+	if q.CreatedAfter != nil && !task.CreatedAt.After(*q.CreatedAfter) {
+		return false
+	}
+	if q.CreatedBefore != nil && !task.CreatedAt.Before(*q.CreatedBefore) {
+		return false
+	}
+	if q.Priority != "" && task.Priority != q.Priority {
+		return false
+	}
+	if q.Tag != "" && !task.HasTag(q.Tag) {
+		return false
+	}
+	if q.DueAfter != nil && (task.DueAt == nil || !task.DueAt.After(*q.DueAfter)) {
+		return false
+	}
+	if q.DueBefore != nil && (task.DueAt == nil || !task.DueAt.Before(*q.DueBefore)) {
+		return false
+	}
+	return true
+}
+
+// less orders two tasks by the sort field, breaking ties by ID so pages
+// stay stable between requests.
+func (q TaskQuery) less(a, b *Task) bool {
+	field, desc := q.Sort, false
+	if len(field) > 0 && field[0] == '-' {
+		field, desc = field[1:], true
+	}
+
+	var cmp int
+	switch field {
+	case "created_at":
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	case "completed_at":
+		cmp = compareOptionalTime(a.CompletedAt, b.CompletedAt)
+	case "due_at":
+		cmp = compareOptionalTime(a.DueAt, b.DueAt)
+	case "deleted_at":
+		cmp = compareOptionalTime(a.DeletedAt, b.DeletedAt)
+	case "priority":
+		cmp = a.Priority.Rank() - b.Priority.Rank()
+	}
+	if cmp == 0 {
+		cmp = a.ID - b.ID
+	}
+	if desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// compareOptionalTime sorts unset times after set ones.
+func compareOptionalTime(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+// ApplyRanked filters and pages search results. They keep their
+// relevance order unless Sort is set. Snippets are only built for the
+// returned page.
+func (q TaskQuery) ApplyRanked(results []SearchResult) ([]SearchResult, int) {
+	matched := []SearchResult{}
+	for _, result := range results {
+		if q.matches(result.Task) {
+			matched = append(matched, result)
+		}
+	}
+	if q.Sort != "" {
+		sort.SliceStable(matched, func(i, j int) bool {
+			return q.less(matched[i].Task, matched[j].Task)
+		})
+	}
+	results = page(matched, q.Offset, q.Limit)
+	for i := range results {
+		results[i].Snippet = results[i].hit.Snippet(searchTexts(results[i].Task)...)
+	}
+	return results, len(matched)
+}
+
+// page cuts the [offset, offset+limit) window out of items.
+func page[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := len(items)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return items[offset:end]
+}
+
+// Apply returns the requested page of matching tasks and the number of
+// matches before paging.
+func (q TaskQuery) Apply(tasks []*Task) ([]*Task, int) {
+	matched := []*Task{}
+	for _, task := range tasks {
+		if q.matches(task) {
+			matched = append(matched, task)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return q.less(matched[i], matched[j])
+	})
+
+	return page(matched, q.Offset, q.Limit), len(matched)
+}
@@ -1,12 +1,117 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// Priorities lists every valid priority from lowest to highest.
+var Priorities = []Priority{PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// Rank orders priorities; an unset priority ranks below low.
+func (p Priority) Rank() int {
+	for i, known := range Priorities {
+		if p == known {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Valid reports whether p is empty or one of Priorities.
+func (p Priority) Valid() bool {
+	return p == "" || p.Rank() > 0
+}
+
 type TaskData struct {
-	Description string `json:"description" validate:"required,min=3"`
+	Description string     `json:"description" validate:"required,min=3,max=500"`
+	Priority    Priority   `json:"priority" validate:"omitempty,oneof=low medium high urgent"`
+	DueAt       *time.Time `json:"due_at"`
+	Tags        []string   `json:"tags" validate:"max=20,dive,max=40"`
+	Notes       string     `json:"notes" validate:"max=5000"`
+	ProjectID   int        `json:"project_id" validate:"min=0"`
+	Assignee    string     `json:"assignee" validate:"max=100"`
+}
+
+// Normalize trims free text and tidies tags ahead of validation.
+func (d *TaskData) Normalize() {
+	d.Description = strings.TrimSpace(d.Description)
+	d.Assignee = strings.TrimSpace(d.Assignee)
+	d.Notes = strings.TrimSpace(d.Notes)
+	d.Tags = NormalizeTags(d.Tags)
+}
+
+// TaskUpdate is the body of PUT /tasks/{id}; every field is replaced.
+type TaskUpdate struct {
+	Description string     `json:"description" validate:"required,min=3,max=500"`
+	Completed   bool       `json:"complete"`
+	Priority    Priority   `json:"priority" validate:"omitempty,oneof=low medium high urgent"`
+	DueAt       *time.Time `json:"due_at"`
+	Tags        []string   `json:"tags" validate:"max=20,dive,max=40"`
+	Notes       string     `json:"notes" validate:"max=5000"`
+	Assignee    string     `json:"assignee" validate:"max=100"`
+}
+
+func (u *TaskUpdate) Normalize() {
+	u.Description = strings.TrimSpace(u.Description)
+	u.Assignee = strings.TrimSpace(u.Assignee)
+	u.Notes = strings.TrimSpace(u.Notes)
+	u.Tags = NormalizeTags(u.Tags)
+}
+
+// TaskPatch is the body of PATCH /tasks/{id}; only fields present in the
+// request are changed.
+type TaskPatch struct {
+	Description *string      `json:"description" validate:"omitempty,required,min=3,max=500"`
+	Completed   *bool        `json:"complete"`
+	Priority    *Priority    `json:"priority" validate:"omitempty,oneof=low medium high urgent"`
+	DueAt       OptionalTime `json:"due_at"`
+	Tags        *[]string    `json:"tags" validate:"omitempty,max=20,dive,max=40"`
+	Notes       *string      `json:"notes" validate:"omitempty,max=5000"`
+	Assignee    *string      `json:"assignee" validate:"omitempty,max=100"`
+}
+
+// OptionalTime is a PATCH field that tells an explicit null from an
+// absent field: Set records that the field was sent, and a nil At then
+// clears the value.
+type OptionalTime struct {
+	Set bool
+	At  *time.Time
+}
+
+func (o *OptionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.At)
+}
+
+func (p *TaskPatch) Normalize() {
+	if p.Description != nil {
+		description := strings.TrimSpace(*p.Description)
+		p.Description = &description
+	}
+	if p.Notes != nil {
+		notes := strings.TrimSpace(*p.Notes)
+		p.Notes = &notes
+	}
+	if p.Tags != nil {
+		tags := NormalizeTags(*p.Tags)
+		p.Tags = &tags
+	}
+	if p.Assignee != nil {
+		assignee := strings.TrimSpace(*p.Assignee)
+		p.Assignee = &assignee
+	}
 }
 
 // Task Model with helper methods and Constructor
@@ -16,6 +121,18 @@ type Task struct {
 	Completed   bool       `json:"complete"`
 	CreatedAt   time.Time  `json:"created_at"`
 	CompletedAt *time.Time `json:"completed_at"`
+	Priority    Priority   `json:"priority,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+	Version     int        `json:"version"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	// Owner is the subject of the principal that created the task; it is
+	// empty for tasks created while auth was off.
+	Owner string `json:"owner,omitempty"`
+	// ProjectID is the project the task belongs to; 0 for a personal task.
+	ProjectID int    `json:"project_id,omitempty"`
+	Assignee  string `json:"assignee,omitempty"`
 }
 
 func NewTask(id int, description string) *Task {
@@ -24,7 +141,60 @@ func NewTask(id int, description string) *Task {
 		Description: description,
 		Completed:   false,
 		CreatedAt:   time.Now(),
+		Version:     1,
+	}
+}
+
+// ETag identifies this revision of the task for conditional requests.
+func (t *Task) ETag() string {
+	return fmt.Sprintf(`"%d-%d"`, t.ID, t.Version)
+}
+
+// Clone returns a deep copy that shares no slices or pointers with t.
+func (t *Task) Clone() *Task {
+	c := *t
+	if t.CompletedAt != nil {
+		at := *t.CompletedAt
+		c.CompletedAt = &at
 	}
+	if t.DueAt != nil {
+		due := *t.DueAt
+		c.DueAt = &due
+	}
+	if t.Tags != nil {
+		c.Tags = append([]string(nil), t.Tags...)
+	}
+	if t.DeletedAt != nil {
+		deleted := *t.DeletedAt
+		c.DeletedAt = &deleted
+	}
+	return &c
+}
+
+// HasTag reports whether the task carries tag, ignoring case.
+func (t *Task) HasTag(tag string) bool {
+	for _, have := range t.Tags {
+		if strings.EqualFold(have, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags trims tags and drops empty and duplicate entries.
+func NormalizeTags(tags []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, tag)
+	}
+	return out
 }
 
 func (t *Task) Complete() {
@@ -33,6 +203,38 @@ func (t *Task) Complete() {
 	t.CompletedAt = &now
 }
 
+func (t *Task) Reopen() {
+	t.Completed = false
+	t.CompletedAt = nil
+}
+
+// SetCompleted moves the task to the requested state, keeping the
+// original CompletedAt when it is already complete.
+func (t *Task) SetCompleted(done bool) {
+	switch {
+	case done && !t.Completed:
+		t.Complete()
+	case !done && t.Completed:
+		t.Reopen()
+	}
+}
+
+// Deleted reports whether the task is in the trash.
+func (t *Task) Deleted() bool {
+	return t.DeletedAt != nil
+}
+
+// Trash soft-deletes the task; it stays stored until purged.
+func (t *Task) Trash() {
+	now := time.Now()
+	t.DeletedAt = &now
+}
+
+// Restore takes the task back out of the trash.
+func (t *Task) Restore() {
+	t.DeletedAt = nil
+}
+
 func (t *Task) String() string {
 	status := "[ ]"
 	if t.Completed {
@@ -40,3 +242,11 @@ func (t *Task) String() string {
 	}
 	return fmt.Sprintf("%d. %s %s", t.ID, status, t.Description)
 }
+
+type TaskNotFoundError struct {
+	ID int
+}
+
+func (e TaskNotFoundError) Error() string {
+	return fmt.Sprintf("task with ID %d not found", e.ID)
+}
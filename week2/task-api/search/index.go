@@ -0,0 +1,354 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BM25 tuning: k1 caps how much repeated terms help, b how much long
+// documents are penalised.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Field is one indexed part of a document. Matches in heavier fields
+// rank higher.
+type Field struct {
+	Name   string
+	Weight float64
+}
+
+// Hit is one document matched by a query.
+type Hit struct {
+	ID    int
+	Score float64
+
+	// field is the first field with a match and marks the token
+	// positions matched in it; Snippet renders them.
+	field int
+	marks map[int]bool
+}
+
+type document struct {
+	texts  []string
+	length int // tokens across all fields
+}
+
+// positions lists, per field, where a term occurs in a document.
+type positions [][]int
+
+// Index is an inverted index from normalized terms to the documents and
+// positions they occur at. It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	fields   []Field
+	docs     map[int]*document
+	postings map[string]map[int]positions
+	terms    []string // sorted vocabulary, for prefix queries
+	totalLen int
+}
+
+func NewIndex(fields ...Field) *Index {
+	return &Index{
+		fields:   fields,
+		docs:     map[int]*document{},
+		postings: map[string]map[int]positions{},
+	}
+}
+
+// Len reports how many documents are indexed.
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.docs)
+}
+
+// Add indexes a document, replacing any earlier version with the same
+// ID. texts holds one text per field, in the order given to NewIndex.
+func (ix *Index) Add(id int, texts ...string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+	doc := &document{texts: texts}
+	for f, text := range texts {
+		if f >= len(ix.fields) {
+			break
+		}
+		for pos, token := range Tokenize(text) {
+			docs, ok := ix.postings[token.Term]
+			if !ok {
+				docs = map[int]positions{}
+				ix.postings[token.Term] = docs
+				ix.insertTerm(token.Term)
+			}
+			p := docs[id]
+			if p == nil {
+				p = make(positions, len(ix.fields))
+				docs[id] = p
+			}
+			p[f] = append(p[f], pos)
+			doc.length++
+		}
+	}
+	ix.docs[id] = doc
+	ix.totalLen += doc.length
+}
+
+// Remove drops a document from the index.
+func (ix *Index) Remove(id int) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id int) {
+	doc, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for _, text := range doc.texts {
+		for _, token := range Tokenize(text) {
+			docs := ix.postings[token.Term]
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(ix.postings, token.Term)
+				ix.deleteTerm(token.Term)
+			}
+		}
+	}
+	ix.totalLen -= doc.length
+	delete(ix.docs, id)
+}
+
+func (ix *Index) insertTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	ix.terms = append(ix.terms, "")
+	copy(ix.terms[i+1:], ix.terms[i:])
+	ix.terms[i] = term
+}
+
+func (ix *Index) deleteTerm(term string) {
+	i := sort.SearchStrings(ix.terms, term)
+	if i < len(ix.terms) && ix.terms[i] == term {
+		ix.terms = append(ix.terms[:i], ix.terms[i+1:]...)
+	}
+}
+
+// expand lists the indexed terms starting with prefix.
+func (ix *Index) expand(prefix string) []string {
+	i := sort.SearchStrings(ix.terms, prefix)
+	var out []string
+	for ; i < len(ix.terms) && strings.HasPrefix(ix.terms[i], prefix); i++ {
+		out = append(out, ix.terms[i])
+	}
+	return out
+}
+
+// match is the running score of one document and the token positions to
+// highlight, keyed by field.
+type match struct {
+	score float64
+	marks map[int]map[int]bool
+}
+
+func (m *match) mark(field, pos int) {
+	if m.marks == nil {
+		m.marks = map[int]map[int]bool{}
+	}
+	if m.marks[field] == nil {
+		m.marks[field] = map[int]bool{}
+	}
+	m.marks[field][pos] = true
+}
+
+func (m *match) merge(other *match) {
+	m.score += other.score
+	for field, marks := range other.marks {
+		for pos := range marks {
+			m.mark(field, pos)
+		}
+	}
+}
+
+// Search returns every document matching q, best first. Ties are broken
+// by ID so results are stable.
+func (ix *Index) Search(q *Query) []Hit {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var result map[int]*match
+	for i, clause := range q.Clauses {
+		matched := map[int]*match{}
+		for _, atom := range clause {
+			for id, m := range ix.evalAtom(atom) {
+				if prev, ok := matched[id]; ok {
+					prev.merge(m)
+				} else {
+					matched[id] = m
+				}
+			}
+		}
+		if i == 0 {
+			result = matched
+			continue
+		}
+		for id, m := range result {
+			other, ok := matched[id]
+			if !ok {
+				delete(result, id)
+				continue
+			}
+			m.merge(other)
+		}
+	}
+
+	hits := make([]Hit, 0, len(result))
+	for id, m := range result {
+		hit := Hit{ID: id, Score: m.score, field: -1}
+		for f := range ix.fields {
+			if len(m.marks[f]) > 0 {
+				hit.field, hit.marks = f, m.marks[f]
+				break
+			}
+		}
+		hits = append(hits, hit)
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	return hits
+}
+
+func (ix *Index) evalAtom(atom Atom) map[int]*match {
+	if !atom.Prefix {
+		return ix.evalPhrase(atom.Terms)
+	}
+
+	last := len(atom.Terms) - 1
+	out := map[int]*match{}
+	for _, term := range ix.expand(atom.Terms[last]) {
+		terms := append(append([]string(nil), atom.Terms[:last]...), term)
+		for id, m := range ix.evalPhrase(terms) {
+			if prev, ok := out[id]; ok {
+				prev.merge(m)
+			} else {
+				out[id] = m
+			}
+		}
+	}
+	return out
+}
+
+// evalPhrase matches documents where terms occur next to each other in
+// one field; a single term is a phrase of one.
+func (ix *Index) evalPhrase(terms []string) map[int]*match {
+	first := ix.postings[terms[0]]
+	out := map[int]*match{}
+
+	var idf float64
+	for _, term := range terms {
+		idf += ix.idf(len(ix.postings[term]))
+	}
+
+	for id, firstPos := range first {
+		m := &match{}
+		for f := range ix.fields {
+			freq := 0
+		starts:
+			for _, start := range firstPos[f] {
+				for k := 1; k < len(terms); k++ {
+					p, ok := ix.postings[terms[k]][id]
+					if !ok || !contains(p[f], start+k) {
+						continue starts
+					}
+				}
+				freq++
+				for k := range terms {
+					m.mark(f, start+k)
+				}
+			}
+			if freq > 0 {
+				m.score += ix.fields[f].Weight * idf * ix.saturate(freq, ix.docs[id].length)
+			}
+		}
+		if m.score > 0 {
+			out[id] = m
+		}
+	}
+	return out
+}
+
+func contains(sorted []int, v int) bool {
+	i := sort.SearchInts(sorted, v)
+	return i < len(sorted) && sorted[i] == v
+}
+
+// idf is the BM25 inverse document frequency of a term found in df
+// documents.
+func (ix *Index) idf(df int) float64 {
+	n := float64(len(ix.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// saturate is the BM25 term-frequency component.
+func (ix *Index) saturate(freq, length int) float64 {
+	avg := float64(ix.totalLen) / float64(len(ix.docs))
+	tf := float64(freq)
+	return tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(length)/avg))
+}
+
+// snippetTokens is how many words a snippet shows.
+const snippetTokens = 16
+
+// Snippet shows the part of the first matching field around its first
+// match, HTML-escaped with every match wrapped in <mark>. texts are the
+// document's fields as they were passed to Add. Building a snippet
+// costs a pass over the field, so callers that page results should only
+// ask for the hits they return.
+func (h Hit) Snippet(texts ...string) string {
+	if h.field < 0 || h.field >= len(texts) {
+		return ""
+	}
+
+	text := texts[h.field]
+	tokens := Tokenize(text)
+	first := len(tokens)
+	for pos := range h.marks {
+		first = min(first, pos)
+	}
+	if first >= len(tokens) {
+		return ""
+	}
+	from := max(0, first-snippetTokens/4)
+	to := min(len(tokens), from+snippetTokens)
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	prev := tokens[from].Start
+	for pos := from; pos < to; pos++ {
+		token := tokens[pos]
+		b.WriteString(html.EscapeString(text[prev:token.Start]))
+		word := html.EscapeString(text[token.Start:token.End])
+		if h.marks[pos] {
+			word = "<mark>" + word + "</mark>"
+		}
+		b.WriteString(word)
+		prev = token.End
+	}
+	if to < len(tokens) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(html.EscapeString(text[prev:]))
+	}
+	return b.String()
+}
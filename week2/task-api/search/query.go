@@ -0,0 +1,133 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Atom is the smallest unit of a query: a single term, a prefix
+// ("buy*") or a phrase of consecutive terms ("quarterly report").
+type Atom struct {
+	Terms  []string
+	Prefix bool
+}
+
+func (a Atom) String() string {
+	s := strings.Join(a.Terms, " ")
+	switch {
+	case a.Prefix:
+		return s + "*"
+	case len(a.Terms) > 1:
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// Query is a conjunction of clauses; each clause matches when any of its
+// atoms does. `a b OR c` parses as a AND (b OR c).
+type Query struct {
+	Clauses [][]Atom
+}
+
+// Empty reports whether the query has nothing to match on.
+func (q *Query) Empty() bool {
+	return len(q.Clauses) == 0
+}
+
+// SyntaxError reports where a query could not be parsed.
+type SyntaxError struct {
+	Pos int // byte offset into the query
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("search query: %s at position %d", e.Msg, e.Pos)
+}
+
+// Parse reads a query. Words separated by spaces must all match; the
+// upper-case keyword OR between two words lets either match instead.
+// Double quotes group a phrase, and a trailing * turns a word into a
+// prefix. Words are normalized like indexed text, so punctuation inside
+// a word ("e-mail") makes it a phrase.
+func Parse(query string) (*Query, error) {
+	q := &Query{}
+	orPending := false
+	orPos := 0
+
+	add := func(atom Atom) {
+		if orPending {
+			last := len(q.Clauses) - 1
+			q.Clauses[last] = append(q.Clauses[last], atom)
+			orPending = false
+			return
+		}
+		q.Clauses = append(q.Clauses, []Atom{atom})
+	}
+
+	for pos := 0; pos < len(query); {
+		r, size := utf8.DecodeRuneInString(query[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+
+		case r == '"':
+			end := strings.IndexByte(query[pos+1:], '"')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: pos, Msg: "unterminated phrase"}
+			}
+			terms := terms(query[pos+1 : pos+1+end])
+			if len(terms) == 0 {
+				return nil, &SyntaxError{Pos: pos, Msg: "empty phrase"}
+			}
+			add(Atom{Terms: terms})
+			pos += end + 2
+
+		default:
+			// r is neither space nor quote, so the word holds at least
+			// that rune and every pass moves forward.
+			end := strings.IndexFunc(query[pos+size:], func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(query) - pos - size
+			}
+			end += size
+			word := query[pos : pos+end]
+
+			if word == "OR" {
+				if len(q.Clauses) == 0 || orPending {
+					return nil, &SyntaxError{Pos: pos, Msg: "OR needs a term on each side"}
+				}
+				orPending, orPos = true, pos
+				pos += end
+				continue
+			}
+
+			prefix := strings.HasSuffix(word, "*")
+			terms := terms(strings.TrimSuffix(word, "*"))
+			switch {
+			case prefix && len(terms) == 0:
+				return nil, &SyntaxError{Pos: pos, Msg: "* must follow a word"}
+			case strings.Contains(strings.TrimSuffix(word, "*"), "*"):
+				return nil, &SyntaxError{Pos: pos + strings.IndexByte(word, '*'), Msg: "* is only allowed at the end of a word"}
+			case len(terms) > 0:
+				add(Atom{Terms: terms, Prefix: prefix})
+			}
+			pos += end
+		}
+	}
+
+	if orPending {
+		return nil, &SyntaxError{Pos: orPos, Msg: "OR needs a term on each side"}
+	}
+	return q, nil
+}
+
+func terms(text string) []string {
+	tokens := Tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return terms
+}
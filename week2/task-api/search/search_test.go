@@ -0,0 +1,182 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	// Case 1: Words split on punctuation, offsets point into the original
+	tokens := Tokenize("Buy milk, e-mail Bob!")
+	var terms []string
+	for _, tok := range tokens {
+		terms = append(terms, tok.Term)
+	}
+	if want := []string{"buy", "milk", "e", "mail", "bob"}; !reflect.DeepEqual(terms, want) {
+		t.Errorf("Expected %v, got %v", want, terms)
+	}
+	if tok := tokens[4]; tok.Start != 17 || tok.End != 20 {
+		t.Errorf("Unexpected offsets for Bob: %+v", tok)
+	}
+
+	// Case 2: Case folding and Unicode normalization
+	for _, word := range []string{"CAFÉ", "Café", "ＣＡＦÉ"} {
+		if got := Normalize(word); got != "café" {
+			t.Errorf("Normalize(%q) = %q, want café", word, got)
+		}
+	}
+	if got := Normalize("STRASSE"); got != Normalize("straße") {
+		t.Errorf("Expected full case folding, got %q vs %q", got, Normalize("straße"))
+	}
+}
+
+func TestParse(t *testing.T) {
+	// Case 1: Implicit AND, OR, phrases and prefixes
+	q, err := Parse(`buy OR get "oat milk" rep*`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var clauses []string
+	for _, clause := range q.Clauses {
+		var atoms []string
+		for _, atom := range clause {
+			atoms = append(atoms, atom.String())
+		}
+		clauses = append(clauses, strings.Join(atoms, " | "))
+	}
+	if want := []string{"buy | get", `"oat milk"`, "rep*"}; !reflect.DeepEqual(clauses, want) {
+		t.Errorf("Expected %q, got %q", want, clauses)
+	}
+
+	// Case 2: Errors report where they happened
+	cases := map[string]int{
+		`"unterminated`: 0,
+		`OR milk`:       0,
+		`milk OR`:       5,
+		`milk OR OR x`:  8,
+		`bu*y`:          2,
+		`*`:             0,
+		`a ""`:          2,
+	}
+	for query, pos := range cases {
+		_, err := Parse(query)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Pos != pos {
+			t.Errorf("Parse(%q): expected error at %d, got %v", query, pos, err)
+		}
+	}
+
+	// Case 3: Punctuation-only queries are empty
+	if q, err := Parse(` ?! `); err != nil || !q.Empty() {
+		t.Errorf("Expected empty query, got %+v (%v)", q, err)
+	}
+
+	// Case 4: Multi-byte whitespace separates words (and used to hang)
+	for query, want := range map[string]int{
+		"milk\u00a0":       1,
+		"\u3000":           0,
+		"buy\u3000milk":    2,
+		"\u00a0\u2003oat ": 1,
+		"\xffmilk":         1,
+	} {
+		q, err := Parse(query)
+		if err != nil || len(q.Clauses) != want {
+			t.Errorf("Parse(%q): expected %d clauses, got %+v (%v)", query, want, q, err)
+		}
+	}
+}
+
+func newTestIndex() *Index {
+	ix := NewIndex(Field{Name: "title", Weight: 3}, Field{Name: "body", Weight: 1})
+	ix.Add(1, "Buy milk", "from the corner shop")
+	ix.Add(2, "Write quarterly report", "include the milk budget")
+	ix.Add(3, "Report bug", "the quarterly numbers are off in the report")
+	ix.Add(4, "Buying guide", "")
+	return ix
+}
+
+func ids(hits []Hit) []int {
+	out := []int{}
+	for _, hit := range hits {
+		out = append(out, hit.ID)
+	}
+	return out
+}
+
+func search(t *testing.T, ix *Index, query string) []Hit {
+	t.Helper()
+	q, err := Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", query, err)
+	}
+	return ix.Search(q)
+}
+
+func TestIndex_Search(t *testing.T) {
+	ix := newTestIndex()
+
+	cases := []struct {
+		query string
+		want  []int
+	}{
+		{"milk", []int{1, 2}},             // title match outranks body match
+		{"MILK budget", []int{2}},         // AND
+		{"shop OR budget", []int{1, 2}},   // OR
+		{`"quarterly report"`, []int{2}},  // phrase, not just both words
+		{"quarterly report", []int{2, 3}}, // both in the title beats repeats in the body
+		{"buy*", []int{4, 1}},             // prefix; the shorter document ranks first
+		{"nothing", []int{}},
+	}
+	for _, c := range cases {
+		if got := ids(search(t, ix, c.query)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: expected %v, got %v", c.query, c.want, got)
+		}
+	}
+}
+
+func TestIndex_UpdateAndRemove(t *testing.T) {
+	ix := newTestIndex()
+
+	// Case 1: Re-adding replaces the old text
+	ix.Add(1, "Buy bread", "")
+	if got := ids(search(t, ix, "milk")); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("Expected only task 2 for milk, got %v", got)
+	}
+
+	// Case 2: Removed documents and their unique terms disappear
+	ix.Remove(4)
+	if got := ids(search(t, ix, "buying*")); len(got) != 0 {
+		t.Errorf("Expected removed doc to be gone, got %v", got)
+	}
+	if ix.Len() != 3 {
+		t.Errorf("Expected 3 docs, got %d", ix.Len())
+	}
+	for _, term := range ix.terms {
+		if term == "buying" || term == "guide" {
+			t.Errorf("Term %q survived removal", term)
+		}
+	}
+}
+
+func TestIndex_Snippet(t *testing.T) {
+	ix := NewIndex(Field{Name: "title", Weight: 1}, Field{Name: "body", Weight: 1})
+	texts := []string{"Plan", "one two three four five six seven eight <b>Milk</b> nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen"}
+	ix.Add(1, texts...)
+
+	hits := search(t, ix, "milk")
+	if len(hits) != 1 {
+		t.Fatalf("Expected 1 hit, got %d", len(hits))
+	}
+	want := "…six seven eight &lt;b&gt;<mark>Milk</mark>&lt;/b&gt; nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen"
+	if got := hits[0].Snippet(texts...); got != want {
+		t.Errorf("Unexpected snippet:\n got %q\nwant %q", got, want)
+	}
+
+	// Case 2: Phrase matches mark every word; title matches win the snippet
+	ix.Add(2, "Oat milk latte", "")
+	if hits := search(t, ix, `"oat milk"`); len(hits) != 1 || hits[0].Snippet("Oat milk latte", "") != "<mark>Oat</mark> <mark>milk</mark> latte" {
+		t.Errorf("Unexpected phrase snippet: %+v", hits)
+	}
+}
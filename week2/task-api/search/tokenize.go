@@ -0,0 +1,55 @@
+// Package search is a small in-memory full-text engine: an inverted
+// index over a fixed set of weighted fields, a query language with AND,
+// OR, quoted phrases and prefix terms, BM25 ranking and highlighted
+// snippets.
+package search
+
+import (
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Token is one word of a text. Start and End are byte offsets into the
+// original text; Term is the normalized form that is indexed.
+type Token struct {
+	Term       string
+	Start, End int
+}
+
+// isWordRune reports whether r belongs to a word. Combining marks stay
+// with the letter they modify.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.In(r, unicode.Mn, unicode.Mc)
+}
+
+// Normalize brings a word to its indexed form: Unicode NFKC followed by
+// full case folding, so "Ｃafé", "CAFÉ" and "café" all index alike. No
+// stemming is done.
+func Normalize(word string) string {
+	return cases.Fold().String(norm.NFKC.String(word))
+}
+
+// Tokenize splits text into words at every rune that is not a letter,
+// digit or combining mark.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: Normalize(text[start:i]), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Term: Normalize(text[start:]), Start: start, End: len(text)})
+	}
+	return tokens
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// backupName is where the last good copy of filename is kept.
+func backupName(filename string) string {
+	return filename + ".bak"
+}
+
+// WriteFileAtomic writes data to a temp file in the same directory, fsyncs
+// it and renames it over filename, so readers see either the old or the
+// new contents and never a truncated file.
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filename)
+	tmp, err := os.CreateTemp(dir, filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // no-op once the rename succeeded
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the directory entry so the rename itself survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Some platforms do not support fsync on directories; the data
+	// itself is already durable at this point.
+	d.Sync()
+	return nil
+}
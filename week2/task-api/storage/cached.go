@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"log"
+	"sync"
+	"task-api/models"
+	"time"
+)
+
+type opKind int
+
+const (
+	opCreate opKind = iota
+	opUpdate
+	opDelete
+)
+
+// pendingOp is the latest unsaved change for one task ID.
+type pendingOp struct {
+	kind opKind
+	task *models.Task
+}
+
+// CachedStore is the server's single source of truth: one long-lived
+// TaskManager, loaded from the backend at startup and guarded by a
+// mutex. Reads never touch the backend; writes are applied in memory
+// immediately and persisted to the backend in the background.
+type CachedStore struct {
+	mu      sync.RWMutex
+	tm      *models.TaskManager
+	pending map[int]pendingOp
+
+	backend TaskStore
+	flushMu sync.Mutex // serialises flushes so backend ops stay ordered
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// NewCachedStore loads every task from backend and starts a goroutine
+// that flushes pending writes every interval.
+func NewCachedStore(backend TaskStore, interval time.Duration) (*CachedStore, error) {
+	tasks, err := backend.List()
+	if err != nil {
+		return nil, err
+	}
+	tm := models.NewTaskManager()
+	tm.Load(tasks)
+
+	s := &CachedStore{
+		tm:      tm,
+		pending: map[int]pendingOp{},
+		backend: backend,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go s.flushLoop(interval)
+	return s, nil
+}
+
+func (s *CachedStore) flushLoop(interval time.Duration) {
+	defer close(s.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				log.Printf("storage: write-behind flush failed: %v", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close stops the background loop and writes out anything still pending.
+func (s *CachedStore) Close() error {
+	s.once.Do(func() { close(s.stop) })
+	<-s.stopped
+	return s.Flush()
+}
+
+// queue records a change, folding it into any unsaved change for the
+// same task so the backend only sees the net effect.
+func (s *CachedStore) queue(kind opKind, id int, task *models.Task) {
+	prev, ok := s.pending[id]
+	switch {
+	case ok && prev.kind == opCreate && kind == opDelete:
+		delete(s.pending, id) // never reached the backend
+		return
+	case ok && prev.kind == opCreate:
+		kind = opCreate
+	}
+	s.pending[id] = pendingOp{kind: kind, task: task}
+}
+
+// requeue puts back a change the backend rejected. A newer change for the
+// same task wins, but it must still create the task if the failed change
+// would have.
+func (s *CachedStore) requeue(id int, op pendingOp) {
+	newer, ok := s.pending[id]
+	switch {
+	case !ok:
+		s.pending[id] = op
+	case op.kind == opCreate && newer.kind == opDelete:
+		delete(s.pending, id)
+	case op.kind == opCreate:
+		newer.kind = opCreate
+		s.pending[id] = newer
+	}
+}
+
+// applyOp hands one pending change to store.
+func applyOp(store TaskStore, id int, op pendingOp) error {
+	switch op.kind {
+	case opCreate:
+		return store.Create(copyTask(op.task))
+	case opUpdate:
+		return store.Update(copyTask(op.task))
+	default:
+		return store.Delete(id)
+	}
+}
+
+// Flush writes every pending change to the backend in one transaction.
+// If the backend rejects it, the changes are retried one at a time so a
+// single bad change cannot hold up the rest; those that still fail are
+// requeued for the next flush.
+func (s *CachedStore) Flush() error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	s.mu.Lock()
+	batch := s.pending
+	s.pending = map[int]pendingOp{}
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	err := s.backend.Atomic(func(tx TaskStore) error {
+		for id, op := range batch {
+			if err := applyOp(tx, id, op); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	var firstErr error
+	for id, op := range batch {
+		err := applyOp(s.backend, id, op)
+		if err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		s.mu.Lock()
+		s.requeue(id, op)
+		s.mu.Unlock()
+	}
+	return firstErr
+}
+
+func (s *CachedStore) Get(id int) (*models.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	task := s.tm.Get(id)
+	if task == nil {
+		return nil, models.TaskNotFoundError{ID: id}
+	}
+	return copyTask(task), nil
+}
+
+func (s *CachedStore) List() ([]*models.Task, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyTasks(s.tm.List()), nil
+}
+
+func (s *CachedStore) Create(task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.tm.Insert(copyTask(task))
+	task.ID = stored.ID
+	s.queue(opCreate, stored.ID, copyTask(stored))
+	return nil
+}
+
+func (s *CachedStore) Update(task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.tm.Replace(copyTask(task)) {
+		return models.TaskNotFoundError{ID: task.ID}
+	}
+	s.queue(opUpdate, task.ID, copyTask(task))
+	return nil
+}
+
+func (s *CachedStore) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.tm.Modify(id, change)
+	if err != nil {
+		return nil, err
+	}
+	s.queue(opUpdate, id, copyTask(task))
+	return copyTask(task), nil
+}
+
+func (s *CachedStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.tm.Delete(id) {
+		return models.TaskNotFoundError{ID: id}
+	}
+	s.queue(opDelete, id, nil)
+	return nil
+}
+
+// Query filters and pages in memory and copies only the page.
+func (s *CachedStore) Query(q models.TaskQuery) ([]*models.Task, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	page, total := q.Apply(s.tm.List())
+	return copyTasks(page), total, nil
+}
+
+func (s *CachedStore) Search(query string) ([]models.SearchResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return copyResults(s.tm.Search(query))
+}
+
+// Atomic runs fn on a copy of the cache and applies its writes to the
+// cache only if fn succeeds. The writes are queued together, so the next
+// flush hands them to the backend as one transaction.
+func (s *CachedStore) Atomic(fn func(tx TaskStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &managerTx{tm: s.tm.Clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	tx.commit(s.tm)
+	for _, op := range tx.ops {
+		s.queue(op.kind, op.id, op.task)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"sync"
+	"task-api/models"
+	"testing"
+	"time"
+)
+
+func TestCachedStore_ParallelWrites(t *testing.T) {
+	backend := NewMemoryStore()
+	backend.Create(models.NewTask(0, "Existing"))
+
+	store, err := NewCachedStore(backend, 5*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewCachedStore failed: %v", err)
+	}
+
+	// Hammer create/complete/delete from many goroutines while the
+	// background flush runs.
+	const workers = 50
+	ids := make(chan int, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			task := models.NewTask(0, "Parallel task")
+			if err := store.Create(task); err != nil {
+				t.Errorf("Create failed: %v", err)
+				return
+			}
+			ids <- task.ID
+
+			got, err := store.Get(task.ID)
+			if err != nil {
+				t.Errorf("Get failed: %v", err)
+				return
+			}
+			got.Complete()
+			store.Update(got)
+
+			if i%2 == 0 {
+				if err := store.Delete(task.ID); err != nil {
+					t.Errorf("Delete failed: %v", err)
+				}
+			}
+			store.List()
+			store.Search("parallel")
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	// Case 1: Every create got a distinct ID after the existing task
+	seen := map[int]bool{}
+	for id := range ids {
+		if id < 2 || seen[id] {
+			t.Errorf("Bad or duplicate ID %d", id)
+		}
+		seen[id] = true
+	}
+
+	// Case 2: After Close the backend matches the in-memory view
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	cached, _ := store.List()
+	persisted, _ := backend.List()
+	if len(cached) != 1+workers/2 || len(persisted) != len(cached) {
+		t.Fatalf("Expected %d tasks, cache has %d, backend has %d", 1+workers/2, len(cached), len(persisted))
+	}
+	for _, task := range cached {
+		got, err := backend.Get(task.ID)
+		if err != nil {
+			t.Errorf("Task %d missing from backend", task.ID)
+			continue
+		}
+		if task.ID != 1 && !got.Completed {
+			t.Errorf("Completion of task %d was not persisted", task.ID)
+		}
+	}
+}
+
+func TestCachedStore_CreateThenDeleteNeverReachesBackend(t *testing.T) {
+	backend := NewMemoryStore()
+	store, _ := NewCachedStore(backend, time.Hour)
+
+	task := models.NewTask(0, "Short lived")
+	store.Create(task)
+	store.Delete(task.ID)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if tasks, _ := backend.List(); len(tasks) != 0 {
+		t.Errorf("Expected empty backend, got %v", tasks)
+	}
+}
+
+func TestCachedStore_AtomicKeepsSearchIndex(t *testing.T) {
+	store, _ := NewCachedStore(NewMemoryStore(), time.Hour)
+	defer store.Close()
+
+	kept := models.NewTask(0, "Write report")
+	gone := models.NewTask(0, "Write tests")
+	store.Create(kept)
+	store.Create(gone)
+	store.Search("write") // builds the index
+	tm := store.tm
+
+	err := store.Atomic(func(tx TaskStore) error {
+		if err := tx.Create(models.NewTask(0, "Write docs")); err != nil {
+			return err
+		}
+		if _, err := tx.Modify(kept.ID, func(task *models.Task) error {
+			task.Description = "Review report"
+			return nil
+		}); err != nil {
+			return err
+		}
+		return tx.Delete(gone.ID)
+	})
+	if err != nil {
+		t.Fatalf("Atomic failed: %v", err)
+	}
+
+	// Case 1: The batch was applied to the cached TaskManager, not a clone
+	if store.tm != tm {
+		t.Error("Atomic replaced the cached TaskManager and dropped its search index")
+	}
+
+	// Case 2: Search sees the created, updated and deleted tasks
+	results, _ := store.Search("write")
+	if len(results) != 1 || results[0].Task.Description != "Write docs" {
+		t.Errorf("Expected only the created task for 'write', got %v", results)
+	}
+	if results, _ := store.Search("review"); len(results) != 1 || results[0].Task.ID != kept.ID {
+		t.Errorf("Expected the updated task for 'review', got %v", results)
+	}
+}
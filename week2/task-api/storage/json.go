@@ -2,21 +2,61 @@ package storage
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"os"
 	"task-api/models"
 )
 
 const Filename = "tasks.json"
 
+// SaveTasks atomically replaces filename, first copying the current
+// contents to the backup file if they still parse.
 func SaveTasks(tasks []*models.Task, filename string) error {
 	data, err := json.MarshalIndent(tasks, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filename, data, 0644)
+
+	if prev, err := os.ReadFile(filename); err == nil && len(prev) > 0 && json.Valid(prev) {
+		if err := WriteFileAtomic(backupName(filename), prev, 0644); err != nil {
+			return err
+		}
+	}
+	return WriteFileAtomic(filename, data, 0644)
 }
 
+// LoadTasks reads filename, falling back to the backup copy when the
+// main file is corrupted.
 func LoadTasks(filename string) ([]*models.Task, error) {
+	tasks, err := readTasks(filename)
+	if err == nil {
+		return tasks, nil
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+		return nil, err
+	}
+
+	if !fileExists(backupName(filename)) {
+		return nil, err
+	}
+	backup, backupErr := readTasks(backupName(filename))
+	if backupErr != nil {
+		return nil, err
+	}
+	log.Printf("storage: %s is corrupted (%v), recovered from %s", filename, err, backupName(filename))
+	return backup, nil
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+func readTasks(filename string) ([]*models.Task, error) {
 	data, err := os.ReadFile(filename)
 	if os.IsNotExist(err) {
 		return []*models.Task{}, nil // First run
@@ -34,3 +74,133 @@ func LoadTasks(filename string) ([]*models.Task, error) {
 	err = json.Unmarshal(data, &tasks)
 	return tasks, err
 }
+
+// JSONStore keeps every task in a single JSON file, reading and
+// rewriting the whole file on each operation. An advisory file lock is
+// held across each load-modify-save so concurrent writers, in this
+// process or another, cannot lose each other's updates.
+type JSONStore struct {
+	Filename string
+}
+
+func NewJSONStore(filename string) *JSONStore {
+	return &JSONStore{Filename: filename}
+}
+
+func (s *JSONStore) load() (*models.TaskManager, error) {
+	tasks, err := LoadTasks(s.Filename)
+	if err != nil {
+		return nil, err
+	}
+	tm := models.NewTaskManager()
+	tm.Load(tasks)
+	return tm, nil
+}
+
+// read loads the tasks under a shared lock.
+func (s *JSONStore) read() (*models.TaskManager, error) {
+	unlock, err := lockFile(s.Filename, false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.load()
+}
+
+// modify runs fn under an exclusive lock and saves the result when fn
+// reports a change.
+func (s *JSONStore) modify(fn func(tm *models.TaskManager) (bool, error)) error {
+	unlock, err := lockFile(s.Filename, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tm, err := s.load()
+	if err != nil {
+		return err
+	}
+	changed, err := fn(tm)
+	if err != nil || !changed {
+		return err
+	}
+	return SaveTasks(tm.Tasks, s.Filename)
+}
+
+func (s *JSONStore) Get(id int) (*models.Task, error) {
+	tm, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	task := tm.Get(id)
+	if task == nil {
+		return nil, models.TaskNotFoundError{ID: id}
+	}
+	return task, nil
+}
+
+func (s *JSONStore) List() ([]*models.Task, error) {
+	tm, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return tm.List(), nil
+}
+
+func (s *JSONStore) Create(task *models.Task) error {
+	return s.modify(func(tm *models.TaskManager) (bool, error) {
+		tm.Insert(task)
+		return true, nil
+	})
+}
+
+func (s *JSONStore) Update(task *models.Task) error {
+	return s.modify(func(tm *models.TaskManager) (bool, error) {
+		if !tm.Replace(task) {
+			return false, models.TaskNotFoundError{ID: task.ID}
+		}
+		return true, nil
+	})
+}
+
+func (s *JSONStore) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	var modified *models.Task
+	err := s.modify(func(tm *models.TaskManager) (bool, error) {
+		task, err := tm.Modify(id, change)
+		modified = task
+		return err == nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return modified, nil
+}
+
+func (s *JSONStore) Delete(id int) error {
+	return s.modify(func(tm *models.TaskManager) (bool, error) {
+		if !tm.Delete(id) {
+			return false, models.TaskNotFoundError{ID: id}
+		}
+		return true, nil
+	})
+}
+
+func (s *JSONStore) Search(query string) ([]models.SearchResult, error) {
+	tm, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return tm.Search(query)
+}
+
+// Atomic runs fn under one exclusive lock and rewrites the file once,
+// only if fn succeeds.
+func (s *JSONStore) Atomic(fn func(tx TaskStore) error) error {
+	return s.modify(func(tm *models.TaskManager) (bool, error) {
+		tx := &managerTx{tm: tm}
+		if err := fn(tx); err != nil {
+			return false, err
+		}
+		return len(tx.ops) > 0, nil
+	})
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"task-api/models"
+	"testing"
+)
+
+func TestJSONStore_CRUD(t *testing.T) {
+	store := NewJSONStore(filepath.Join(t.TempDir(), "tasks.json"))
+
+	// Case 1: Create assigns sequential IDs
+	a, b := models.NewTask(0, "Task A"), models.NewTask(0, "Task B")
+	if err := store.Create(a); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	store.Create(b)
+	if a.ID != 1 || b.ID != 2 {
+		t.Errorf("Expected IDs 1 and 2, got %d and %d", a.ID, b.ID)
+	}
+
+	// Case 2: Update is visible to a fresh store on the same file
+	b.Complete()
+	if err := store.Update(b); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	got, err := NewJSONStore(store.Filename).Get(2)
+	if err != nil || !got.Completed {
+		t.Errorf("Expected completed task 2, got %v (%v)", got, err)
+	}
+
+	// Case 3: Delete and missing IDs
+	if err := store.Delete(1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	var nf models.TaskNotFoundError
+	if _, err := store.Get(1); !errors.As(err, &nf) {
+		t.Errorf("Expected TaskNotFoundError, got %v", err)
+	}
+}
+
+func TestJSONStore_ConcurrentCreates(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tasks.json")
+
+	// Separate stores on one file behave like separate processes.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := NewJSONStore(filename).Create(models.NewTask(0, "Concurrent")); err != nil {
+				t.Errorf("Create failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	tasks, _ := NewJSONStore(filename).List()
+	if len(tasks) != 20 {
+		t.Fatalf("Expected 20 tasks, got %d (lost updates)", len(tasks))
+	}
+	seen := map[int]bool{}
+	for _, task := range tasks {
+		if seen[task.ID] {
+			t.Errorf("Duplicate ID %d", task.ID)
+		}
+		seen[task.ID] = true
+	}
+}
+
+func TestLoadTasks_CorruptionFallback(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "tasks.json")
+
+	// Two saves leave the first version in the backup file.
+	SaveTasks([]*models.Task{models.NewTask(1, "A")}, filename)
+	SaveTasks([]*models.Task{models.NewTask(1, "A"), models.NewTask(2, "B")}, filename)
+
+	// Case 1: Corrupted main file falls back to the backup
+	os.WriteFile(filename, []byte(`[{"id": 1, "descr`), 0644)
+	tasks, err := LoadTasks(filename)
+	if err != nil {
+		t.Fatalf("Expected recovery from backup, got %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "A" {
+		t.Errorf("Expected backup contents, got %v", tasks)
+	}
+
+	// Case 2: Saving over a corrupted file keeps the good backup
+	SaveTasks(tasks, filename)
+	if backup, _ := readTasks(backupName(filename)); len(backup) != 1 {
+		t.Errorf("Backup was overwritten, got %v", backup)
+	}
+
+	// Case 3: No backup means the corruption error surfaces
+	other := filepath.Join(t.TempDir(), "tasks.json")
+	os.WriteFile(other, []byte("invalid json: {"), 0644)
+	if _, err := LoadTasks(other); err == nil {
+		t.Error("Expected error when loading corrupted JSON without backup, got nil.")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package storage
+
+import "sync"
+
+// fileLocks stands in for flock on platforms without it; it only
+// serialises access within this process.
+var fileLocks sync.Map
+
+func lockFile(filename string, exclusive bool) (func() error, error) {
+	mu, _ := fileLocks.LoadOrStore(filename, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return func() error {
+		mu.(*sync.Mutex).Unlock()
+		return nil
+	}, nil
+}
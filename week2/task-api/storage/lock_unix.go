@@ -0,0 +1,31 @@
+//go:build unix
+
+package storage
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an advisory flock on filename+".lock" and returns the
+// function that releases it. Shared locks allow concurrent readers.
+func lockFile(filename string, exclusive bool) (func() error, error) {
+	f, err := os.OpenFile(filename+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() error {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return f.Close()
+	}, nil
+}
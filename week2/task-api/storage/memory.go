@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"sync"
+	"task-api/models"
+)
+
+// MemoryStore keeps tasks in process memory only. It is meant for tests
+// and throwaway servers; nothing survives a restart.
+type MemoryStore struct {
+	mu sync.Mutex
+	tm *models.TaskManager
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tm: models.NewTaskManager()}
+}
+
+// copyTask hands out a private copy so callers cannot mutate stored state
+// without going through Update.
+func copyTask(task *models.Task) *models.Task {
+	return task.Clone()
+}
+
+func copyTasks(tasks []*models.Task) []*models.Task {
+	out := make([]*models.Task, 0, len(tasks))
+	for _, task := range tasks {
+		out = append(out, copyTask(task))
+	}
+	return out
+}
+
+// copyResults gives every search result its own copy of the task.
+func copyResults(results []models.SearchResult, err error) ([]models.SearchResult, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Task = copyTask(results[i].Task)
+	}
+	return results, nil
+}
+
+func (s *MemoryStore) Get(id int) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task := s.tm.Get(id)
+	if task == nil {
+		return nil, models.TaskNotFoundError{ID: id}
+	}
+	return copyTask(task), nil
+}
+
+func (s *MemoryStore) List() ([]*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyTasks(s.tm.List()), nil
+}
+
+func (s *MemoryStore) Create(task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.tm.Insert(copyTask(task))
+	task.ID = stored.ID
+	return nil
+}
+
+func (s *MemoryStore) Update(task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tm.Replace(copyTask(task)) {
+		return models.TaskNotFoundError{ID: task.ID}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.tm.Modify(id, change)
+	if err != nil {
+		return nil, err
+	}
+	return copyTask(task), nil
+}
+
+func (s *MemoryStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.tm.Delete(id) {
+		return models.TaskNotFoundError{ID: id}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Search(query string) ([]models.SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyResults(s.tm.Search(query))
+}
+
+// Atomic runs fn on a copy of the tasks and applies its writes only if
+// fn succeeds.
+func (s *MemoryStore) Atomic(fn func(tx TaskStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &managerTx{tm: s.tm.Clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	tx.commit(s.tm)
+	return nil
+}
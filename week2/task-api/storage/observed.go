@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"task-api/models"
+	"time"
+)
+
+// ObservedStore passes every call through to TaskStore and reports its
+// duration and error to Observe, along with the context bound by
+// WithContext. Operations are named after the method, in lower case,
+// unless Names renames them.
+type ObservedStore struct {
+	TaskStore
+	Observe func(ctx context.Context, op string, d time.Duration, err error)
+	Names   map[string]string
+
+	ctx context.Context
+}
+
+// BackendNames are the Names for CachedStore's backend, after how the
+// cache uses it: List is "load", reading everything once at startup,
+// and Atomic is "save", writing each flush in one transaction.
+var BackendNames = map[string]string{"list": "load", "atomic": "save"}
+
+// WithContext returns a copy of s that reports ctx with every call, so
+// observers can tie the call to the request making it.
+func (s *ObservedStore) WithContext(ctx context.Context) TaskStore {
+	bound := *s
+	bound.ctx = ctx
+	return &bound
+}
+
+func (s *ObservedStore) observe(op string, start time.Time, err error) {
+	if name, ok := s.Names[op]; ok {
+		op = name
+	}
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	s.Observe(ctx, op, time.Since(start), err)
+}
+
+// WithContext binds store to ctx if it can report calls per request, as
+// ObservedStore does, and returns it unchanged otherwise.
+func WithContext(store TaskStore, ctx context.Context) TaskStore {
+	if bindable, ok := store.(interface {
+		WithContext(ctx context.Context) TaskStore
+	}); ok {
+		return bindable.WithContext(ctx)
+	}
+	return store
+}
+
+func (s *ObservedStore) Get(id int) (*models.Task, error) {
+	start := time.Now()
+	task, err := s.TaskStore.Get(id)
+	s.observe("get", start, err)
+	return task, err
+}
+
+func (s *ObservedStore) List() ([]*models.Task, error) {
+	start := time.Now()
+	tasks, err := s.TaskStore.List()
+	s.observe("list", start, err)
+	return tasks, err
+}
+
+// Query is observed as "query" whether or not the backend is a Querier.
+func (s *ObservedStore) Query(q models.TaskQuery) ([]*models.Task, int, error) {
+	start := time.Now()
+	tasks, total, err := Query(s.TaskStore, q)
+	s.observe("query", start, err)
+	return tasks, total, err
+}
+
+func (s *ObservedStore) Create(task *models.Task) error {
+	start := time.Now()
+	err := s.TaskStore.Create(task)
+	s.observe("create", start, err)
+	return err
+}
+
+func (s *ObservedStore) Update(task *models.Task) error {
+	start := time.Now()
+	err := s.TaskStore.Update(task)
+	s.observe("update", start, err)
+	return err
+}
+
+func (s *ObservedStore) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	start := time.Now()
+	task, err := s.TaskStore.Modify(id, change)
+	s.observe("modify", start, err)
+	return task, err
+}
+
+func (s *ObservedStore) Delete(id int) error {
+	start := time.Now()
+	err := s.TaskStore.Delete(id)
+	s.observe("delete", start, err)
+	return err
+}
+
+func (s *ObservedStore) Search(query string) ([]models.SearchResult, error) {
+	start := time.Now()
+	results, err := s.TaskStore.Search(query)
+	s.observe("search", start, err)
+	return results, err
+}
+
+// Atomic times the whole transaction; calls fn makes on tx are not
+// observed separately.
+func (s *ObservedStore) Atomic(fn func(tx TaskStore) error) error {
+	start := time.Now()
+	err := s.TaskStore.Atomic(fn)
+	s.observe("atomic", start, err)
+	return err
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"task-api/models"
+)
+
+// ProjectStore persists projects. Implementations must return
+// models.ProjectNotFoundError for unknown IDs.
+type ProjectStore interface {
+	Get(id int) (*models.Project, error)
+	List() ([]*models.Project, error)
+	// Create stores a new project. A zero ID is replaced with the next
+	// free ID.
+	Create(project *models.Project) error
+	// Modify atomically applies change to the stored project and bumps
+	// its Version. An error from change aborts the write.
+	Modify(id int, change func(project *models.Project) error) (*models.Project, error)
+	// Memberships returns subject's role in each project they belong
+	// to, keyed by project ID.
+	Memberships(subject string) (map[int]models.ProjectRole, error)
+}
+
+// projectSet holds projects in memory along with an index from each
+// member to their projects, so looking up a caller's memberships does
+// not scan every project.
+type projectSet struct {
+	projects  []*models.Project
+	nextID    int
+	bySubject map[string]map[int]models.ProjectRole
+}
+
+func newProjectSet() *projectSet {
+	return &projectSet{
+		projects:  []*models.Project{},
+		nextID:    1,
+		bySubject: map[string]map[int]models.ProjectRole{},
+	}
+}
+
+// load replaces the set's projects and moves nextID past the highest ID.
+func (ps *projectSet) load(projects []*models.Project) {
+	*ps = *newProjectSet()
+	for _, project := range projects {
+		ps.put(project)
+	}
+}
+
+func (ps *projectSet) find(id int) int {
+	for i, project := range ps.projects {
+		if project.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// get returns the stored project, not a copy, or nil.
+func (ps *projectSet) get(id int) *models.Project {
+	if i := ps.find(id); i >= 0 {
+		return ps.projects[i]
+	}
+	return nil
+}
+
+func (ps *projectSet) list() []*models.Project {
+	out := make([]*models.Project, 0, len(ps.projects))
+	for _, project := range ps.projects {
+		out = append(out, project.Clone())
+	}
+	return out
+}
+
+// put stores project, replacing the one with the same ID if there is
+// one, and reindexes its members.
+func (ps *projectSet) put(project *models.Project) {
+	if i := ps.find(project.ID); i >= 0 {
+		ps.unindex(ps.projects[i])
+		ps.projects[i] = project
+	} else {
+		ps.projects = append(ps.projects, project)
+	}
+	for _, m := range project.Members {
+		if ps.bySubject[m.Subject] == nil {
+			ps.bySubject[m.Subject] = map[int]models.ProjectRole{}
+		}
+		ps.bySubject[m.Subject][project.ID] = m.Role
+	}
+	ps.nextID = max(ps.nextID, project.ID+1)
+}
+
+// remove drops the project with id, undoing a put that could not be
+// saved.
+func (ps *projectSet) remove(id int) {
+	if i := ps.find(id); i >= 0 {
+		ps.unindex(ps.projects[i])
+		ps.projects = append(ps.projects[:i], ps.projects[i+1:]...)
+	}
+}
+
+func (ps *projectSet) unindex(project *models.Project) {
+	for _, m := range project.Members {
+		delete(ps.bySubject[m.Subject], project.ID)
+		if len(ps.bySubject[m.Subject]) == 0 {
+			delete(ps.bySubject, m.Subject)
+		}
+	}
+}
+
+func (ps *projectSet) memberships(subject string) map[int]models.ProjectRole {
+	out := make(map[int]models.ProjectRole, len(ps.bySubject[subject]))
+	for id, role := range ps.bySubject[subject] {
+		out[id] = role
+	}
+	return out
+}
+
+// JSONProjectStore keeps projects in memory and rewrites a JSON file on
+// every change. Projects are few and change rarely, so unlike tasks they
+// need no cache or event log. An empty filename keeps them in memory
+// only.
+type JSONProjectStore struct {
+	mu       sync.Mutex
+	filename string
+	set      *projectSet
+}
+
+// NewJSONProjectStore loads the projects in filename, if it exists.
+func NewJSONProjectStore(filename string) (*JSONProjectStore, error) {
+	s := &JSONProjectStore{filename: filename, set: newProjectSet()}
+	if filename == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		var projects []*models.Project
+		if err := json.Unmarshal(data, &projects); err != nil {
+			return nil, err
+		}
+		s.set.load(projects)
+	}
+	return s, nil
+}
+
+func NewMemoryProjectStore() *JSONProjectStore {
+	s, _ := NewJSONProjectStore("")
+	return s
+}
+
+func (s *JSONProjectStore) save() error {
+	if s.filename == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.set.projects, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(s.filename, data, 0644)
+}
+
+func (s *JSONProjectStore) Get(id int) (*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	project := s.set.get(id)
+	if project == nil {
+		return nil, models.ProjectNotFoundError{ID: id}
+	}
+	return project.Clone(), nil
+}
+
+func (s *JSONProjectStore) List() ([]*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.list(), nil
+}
+
+func (s *JSONProjectStore) Create(project *models.Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if project.ID == 0 {
+		project.ID = s.set.nextID
+	}
+	if project.Version == 0 {
+		project.Version = 1
+	}
+	nextID := s.set.nextID
+	s.set.put(project.Clone())
+	if err := s.save(); err != nil {
+		s.set.remove(project.ID)
+		s.set.nextID = nextID
+		return err
+	}
+	return nil
+}
+
+func (s *JSONProjectStore) Modify(id int, change func(project *models.Project) error) (*models.Project, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.set.get(id)
+	if old == nil {
+		return nil, models.ProjectNotFoundError{ID: id}
+	}
+
+	project := old.Clone()
+	if err := change(project); err != nil {
+		return nil, err
+	}
+	project.Version++
+	s.set.put(project)
+	if err := s.save(); err != nil {
+		s.set.put(old)
+		return nil, err
+	}
+	return project.Clone(), nil
+}
+
+func (s *JSONProjectStore) Memberships(subject string) (map[int]models.ProjectRole, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set.memberships(subject), nil
+}
+
+// ImportProjects copies the projects in the JSON file filename into dst,
+// IDs included, unless dst already holds projects or the file does not
+// exist. It reports how many were copied. Projects used to live in that
+// file whatever the task backend, so this brings them over on the first
+// start with a backend that keeps its own.
+func ImportProjects(dst ProjectStore, filename string) (int, error) {
+	existing, err := dst.List()
+	if err != nil || len(existing) > 0 {
+		return 0, err
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return 0, nil
+	}
+	src, err := NewJSONProjectStore(filename)
+	if err != nil {
+		return 0, err
+	}
+	projects, _ := src.List()
+	for _, project := range projects {
+		if err := dst.Create(project); err != nil {
+			return 0, fmt.Errorf("importing project %d: %w", project.ID, err)
+		}
+	}
+	return len(projects), nil
+}
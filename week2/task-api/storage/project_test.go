@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"task-api/models"
+	"testing"
+)
+
+// projectStores returns a function per ProjectStore implementation that
+// opens it on the same temp storage each time it is called.
+func projectStores(t *testing.T) map[string]func() ProjectStore {
+	t.Helper()
+	dir := t.TempDir()
+	return map[string]func() ProjectStore{
+		"json": func() ProjectStore {
+			store, err := NewJSONProjectStore(filepath.Join(dir, "projects.json"))
+			if err != nil {
+				t.Fatalf("NewJSONProjectStore failed: %v", err)
+			}
+			return store
+		},
+		"sqlite": func() ProjectStore {
+			store, err := NewSQLiteStore(filepath.Join(dir, "tasks.db"))
+			if err != nil {
+				t.Fatalf("NewSQLiteStore failed: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store.Projects()
+		},
+		"wal": func() ProjectStore {
+			store, err := NewLogStore(filepath.Join(dir, "tasks.log"))
+			if err != nil {
+				t.Fatalf("NewLogStore failed: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			return store.Projects()
+		},
+	}
+}
+
+func TestProjectStores(t *testing.T) {
+	for name, open := range projectStores(t) {
+		t.Run(name, func(t *testing.T) {
+			testProjectStore(t, open)
+		})
+	}
+}
+
+func testProjectStore(t *testing.T, open func() ProjectStore) {
+	store := open()
+
+	// Case 1: Create assigns IDs
+	a, b := models.NewProject(0, "Launch"), models.NewProject(0, "Hiring")
+	a.SetMember("alice", models.RoleOwner)
+	store.Create(a)
+	store.Create(b)
+	if a.ID != 1 || b.ID != 2 {
+		t.Fatalf("Expected IDs 1 and 2, got %d and %d", a.ID, b.ID)
+	}
+
+	// Case 2: Modify bumps the version; an error aborts it
+	got, err := store.Modify(1, func(p *models.Project) error {
+		p.SetMember("bob", models.RoleViewer)
+		return nil
+	})
+	if err != nil || got.Version != 2 || got.Role("bob") != models.RoleViewer {
+		t.Errorf("Modify returned %+v (%v)", got, err)
+	}
+	abort := errors.New("abort")
+	if _, err := store.Modify(1, func(p *models.Project) error {
+		p.RemoveMember("alice")
+		return abort
+	}); !errors.Is(err, abort) {
+		t.Errorf("Expected abort error, got %v", err)
+	}
+
+	// Case 3: Projects survive a reopen and IDs keep counting
+	store = open()
+	got, _ = store.Get(1)
+	if got == nil || got.Role("alice") != models.RoleOwner || got.Role("bob") != models.RoleViewer {
+		t.Errorf("Project did not round-trip: %+v", got)
+	}
+	c := models.NewProject(0, "Ops")
+	store.Create(c)
+	if c.ID != 3 {
+		t.Errorf("Expected ID 3 after reopen, got %d", c.ID)
+	}
+
+	// Case 4: Memberships follow member changes
+	store.Modify(3, func(p *models.Project) error {
+		p.SetMember("bob", models.RoleEditor)
+		return nil
+	})
+	store.Modify(1, func(p *models.Project) error {
+		p.RemoveMember("bob")
+		return nil
+	})
+	if got, _ := store.Memberships("bob"); len(got) != 1 || got[3] != models.RoleEditor {
+		t.Errorf("Unexpected memberships for bob: %v", got)
+	}
+	if got, _ := store.Memberships("mallory"); len(got) != 0 {
+		t.Errorf("Expected no memberships for a stranger, got %v", got)
+	}
+
+	// Case 5: Missing IDs
+	var nf models.ProjectNotFoundError
+	if _, err := store.Get(99); !errors.As(err, &nf) {
+		t.Errorf("Expected ProjectNotFoundError, got %v", err)
+	}
+}
+
+func TestImportProjects(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "projects.json")
+	src, _ := NewJSONProjectStore(legacy)
+	a, b := models.NewProject(0, "Launch"), models.NewProject(0, "Hiring")
+	a.SetMember("alice", models.RoleOwner)
+	src.Create(a)
+	src.Create(b)
+	src.Modify(a.ID, func(p *models.Project) error { return nil }) // version 2
+
+	wal, err := NewLogStore(filepath.Join(dir, "tasks.log"))
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+	defer func() { wal.Close() }()
+	dst := wal.Projects()
+
+	// Case 1: Projects move over with their IDs and versions
+	if n, err := ImportProjects(dst, legacy); n != 2 || err != nil {
+		t.Fatalf("Expected 2 imported projects, got %d (%v)", n, err)
+	}
+	if got, _ := dst.Get(a.ID); got == nil || got.Version != 2 || got.Role("alice") != models.RoleOwner {
+		t.Errorf("Project did not import intact: %+v", got)
+	}
+
+	// Case 2: A store that already has projects is left alone, also once
+	// they are only in the snapshot
+	if err := wal.Compact(); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	wal.Close()
+	wal, err = NewLogStore(filepath.Join(dir, "tasks.log"))
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if n, err := ImportProjects(wal.Projects(), legacy); n != 0 || err != nil {
+		t.Errorf("Expected no second import, got %d (%v)", n, err)
+	}
+	if got, _ := wal.Projects().Memberships("alice"); got[a.ID] != models.RoleOwner {
+		t.Errorf("Memberships lost in the snapshot: %v", got)
+	}
+
+	// Case 3: No legacy file, nothing to do
+	os.Remove(legacy)
+	sqlite, _ := NewSQLiteStore(filepath.Join(dir, "tasks.db"))
+	defer sqlite.Close()
+	if n, err := ImportProjects(sqlite.Projects(), legacy); n != 0 || err != nil {
+		t.Errorf("Expected nothing to import, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,30 @@
+package storage
+
+import "time"
+
+// Purge permanently deletes every task that was trashed before cutoff,
+// in one transaction, and reports how many were removed.
+func Purge(store TaskStore, cutoff time.Time) (int, error) {
+	purged := 0
+	err := store.Atomic(func(tx TaskStore) error {
+		purged = 0
+		tasks, err := tx.List()
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if !task.Deleted() || !task.DeletedAt.Before(cutoff) {
+				continue
+			}
+			if err := tx.Delete(task.ID); err != nil {
+				return err
+			}
+			purged++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
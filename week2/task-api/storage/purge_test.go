@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"task-api/models"
+	"testing"
+	"time"
+)
+
+func TestPurge(t *testing.T) {
+	for name, store := range allStores(t) {
+		t.Run(name, func(t *testing.T) {
+			old := time.Now().AddDate(0, 0, -40)
+			recent := time.Now().AddDate(0, 0, -2)
+			for _, deletedAt := range []*time.Time{nil, &old, &recent} {
+				task := models.NewTask(0, "Task")
+				task.DeletedAt = deletedAt
+				if err := store.Create(task); err != nil {
+					t.Fatalf("Create failed: %v", err)
+				}
+			}
+
+			// Case 1: Only tasks trashed before the cutoff go
+			purged, err := Purge(store, time.Now().AddDate(0, 0, -30))
+			if err != nil || purged != 1 {
+				t.Fatalf("Expected 1 purged, got %d (%v)", purged, err)
+			}
+			if _, err := store.Get(2); err == nil {
+				t.Error("Expected task 2 to be gone")
+			}
+			if tasks, _ := store.List(); len(tasks) != 2 {
+				t.Errorf("Expected 2 tasks left, got %d", len(tasks))
+			}
+
+			// Case 2: Nothing left to purge
+			if purged, _ := Purge(store, time.Now().AddDate(0, 0, -30)); purged != 0 {
+				t.Errorf("Expected nothing purged, got %d", purged)
+			}
+		})
+	}
+}
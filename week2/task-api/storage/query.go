@@ -0,0 +1,25 @@
+package storage
+
+import "task-api/models"
+
+// Querier is implemented by stores that can filter, sort and page tasks
+// themselves instead of handing every task to the caller.
+type Querier interface {
+	// Query returns the page of tasks q selects and how many matched
+	// before paging.
+	Query(q models.TaskQuery) ([]*models.Task, int, error)
+}
+
+// Query runs q through store's own Query when it has one, and otherwise
+// over everything List returns.
+func Query(store TaskStore, q models.TaskQuery) ([]*models.Task, int, error) {
+	if querier, ok := store.(Querier); ok {
+		return querier.Query(q)
+	}
+	tasks, err := store.List()
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := q.Apply(tasks)
+	return page, total, nil
+}
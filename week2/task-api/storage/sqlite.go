@@ -0,0 +1,489 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"task-api/models"
+	"task-api/search"
+	"time"
+
+	_ "modernc.org/sqlite" // pure-Go driver, no cgo required
+)
+
+// migrations are applied in order on startup. Never edit an entry that
+// has shipped; append a new one instead.
+var migrations = []string{
+	`CREATE TABLE tasks (
+		id           INTEGER PRIMARY KEY AUTOINCREMENT,
+		description  TEXT    NOT NULL,
+		completed    INTEGER NOT NULL DEFAULT 0,
+		created_at   TEXT    NOT NULL,
+		completed_at TEXT
+	);
+	CREATE INDEX idx_tasks_completed ON tasks(completed);`,
+
+	`ALTER TABLE tasks ADD COLUMN priority TEXT NOT NULL DEFAULT '';
+	ALTER TABLE tasks ADD COLUMN due_at   TEXT;
+	ALTER TABLE tasks ADD COLUMN tags     TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE tasks ADD COLUMN notes    TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_tasks_due_at ON tasks(due_at);
+	CREATE INDEX idx_tasks_priority ON tasks(priority);`,
+
+	`ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+
+	`ALTER TABLE tasks ADD COLUMN deleted_at TEXT;
+	CREATE INDEX idx_tasks_deleted_at ON tasks(deleted_at);`,
+
+	`ALTER TABLE tasks ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+	CREATE INDEX idx_tasks_owner ON tasks(owner);`,
+
+	`ALTER TABLE tasks ADD COLUMN project_id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE tasks ADD COLUMN assignee   TEXT    NOT NULL DEFAULT '';
+	CREATE INDEX idx_tasks_project_id ON tasks(project_id);`,
+
+	`CREATE TABLE projects (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		name       TEXT    NOT NULL,
+		created_at TEXT    NOT NULL,
+		version    INTEGER NOT NULL DEFAULT 1
+	);
+	CREATE TABLE project_members (
+		project_id INTEGER NOT NULL REFERENCES projects(id),
+		position   INTEGER NOT NULL,
+		subject    TEXT    NOT NULL,
+		role       TEXT    NOT NULL,
+		PRIMARY KEY (project_id, subject)
+	);
+	CREATE INDEX idx_project_members_subject ON project_members(subject);`,
+}
+
+// SQLiteStore persists tasks in a SQLite database, one row per task.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the database at path and brings its
+// schema up to date.
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising through one connection
+	// avoids SQLITE_BUSY errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLiteStore{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const taskColumns = `id, description, completed, created_at, completed_at, priority, due_at, tags, notes, version, deleted_at, owner, project_id, assignee`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (*models.Task, error) {
+	var (
+		task        models.Task
+		createdAt   string
+		completedAt sql.NullString
+		dueAt       sql.NullString
+		tags        string
+		deletedAt   sql.NullString
+	)
+	if err := row.Scan(&task.ID, &task.Description, &task.Completed, &createdAt, &completedAt,
+		&task.Priority, &dueAt, &tags, &task.Notes, &task.Version, &deletedAt, &task.Owner, &task.ProjectID, &task.Assignee); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if task.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, err
+	}
+	if task.CompletedAt, err = parseNullTime(completedAt); err != nil {
+		return nil, err
+	}
+	if task.DueAt, err = parseNullTime(dueAt); err != nil {
+		return nil, err
+	}
+	if task.DeletedAt, err = parseNullTime(deletedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &task.Tags); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func parseNullTime(value sql.NullString) (*time.Time, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatTags stores tags as a JSON array so they round-trip exactly.
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx, so every statement
+// below can run on its own or inside a transaction.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func queryTasks(db querier, where string, args ...any) ([]*models.Task, error) {
+	rows, err := db.Query(`SELECT `+taskColumns+` FROM tasks `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []*models.Task{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// queryFilter turns the filters of q into a WHERE clause. Where and Tag,
+// which folds Unicode case, are left to the caller, which still runs
+// q.Apply over the rows to sort and page them.
+func queryFilter(q models.TaskQuery) (string, []any) {
+	var (
+		conds []string
+		args  []any
+	)
+	add := func(cond string, arg ...any) {
+		conds = append(conds, cond)
+		args = append(args, arg...)
+	}
+	if !q.IncludeDeleted {
+		add(`deleted_at IS NULL`)
+	}
+	if q.Owner != "" {
+		add(`owner = ?`, q.Owner)
+	}
+	if q.ProjectID != 0 {
+		add(`project_id = ?`, q.ProjectID)
+	}
+	if q.Assignee != "" {
+		add(`assignee = ?`, q.Assignee)
+	}
+	if q.Completed != nil {
+		add(`completed = ?`, *q.Completed)
+	}
+	if q.CreatedAfter != nil {
+		add(`created_at > ?`, formatTime(q.CreatedAfter))
+	}
+	if q.CreatedBefore != nil {
+		add(`created_at < ?`, formatTime(q.CreatedBefore))
+	}
+	if q.Priority != "" {
+		add(`priority = ?`, q.Priority)
+	}
+	if q.DueAfter != nil {
+		add(`due_at > ?`, formatTime(q.DueAfter))
+	}
+	if q.DueBefore != nil {
+		add(`due_at < ?`, formatTime(q.DueBefore))
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return `WHERE ` + strings.Join(conds, " AND "), args
+}
+
+// queryPage loads the rows queryFilter lets through and sorts and pages
+// them with q.
+func queryPage(db querier, q models.TaskQuery) ([]*models.Task, int, error) {
+	where, args := queryFilter(q)
+	tasks, err := queryTasks(db, where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := q.Apply(tasks)
+	return page, total, nil
+}
+
+// timeLayout stores times in UTC at a fixed width, so ordering the text
+// orders the instants and WHERE clauses can compare columns directly.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(timeLayout)
+}
+
+func getTask(db querier, id int) (*models.Task, error) {
+	task, err := scanTask(db.QueryRow(`SELECT `+taskColumns+` FROM tasks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.TaskNotFoundError{ID: id}
+	}
+	return task, err
+}
+
+func insertRow(db querier, task *models.Task) error {
+	var id any
+	if task.ID != 0 {
+		id = task.ID
+	}
+	if task.Version == 0 {
+		task.Version = 1
+	}
+	res, err := db.Exec(`INSERT INTO tasks (`+taskColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, task.Description, task.Completed, formatTime(&task.CreatedAt), formatTime(task.CompletedAt),
+		task.Priority, formatTime(task.DueAt), formatTags(task.Tags), task.Notes, task.Version, formatTime(task.DeletedAt),
+		task.Owner, task.ProjectID, task.Assignee)
+	if err != nil {
+		return err
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	task.ID = int(newID)
+	return nil
+}
+
+func updateRow(db querier, task *models.Task) error {
+	res, err := db.Exec(`UPDATE tasks SET description = ?, completed = ?, created_at = ?, completed_at = ?,
+		priority = ?, due_at = ?, tags = ?, notes = ?, version = ?, deleted_at = ?, owner = ?,
+		project_id = ?, assignee = ? WHERE id = ?`,
+		task.Description, task.Completed, formatTime(&task.CreatedAt), formatTime(task.CompletedAt),
+		task.Priority, formatTime(task.DueAt), formatTags(task.Tags), task.Notes, task.Version,
+		formatTime(task.DeletedAt), task.Owner, task.ProjectID, task.Assignee, task.ID)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, task.ID)
+}
+
+// modifyRow is the read-modify-write behind Modify; tx must be a
+// transaction so nothing can land between the read and the write.
+func modifyRow(tx querier, id int, change func(task *models.Task) error) (*models.Task, error) {
+	task, err := getTask(tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	version := task.Version
+	if err := change(task); err != nil {
+		return nil, err
+	}
+	task.ID = id
+	task.Version = version + 1
+	if err := updateRow(tx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func deleteRow(db querier, id int) error {
+	res, err := db.Exec(`DELETE FROM tasks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	return checkAffected(res, id)
+}
+
+func checkAffected(res sql.Result, id int) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.TaskNotFoundError{ID: id}
+	}
+	return nil
+}
+
+// searchText is everything a task is searched by, for LIKE. Tags are
+// their JSON array, whose quotes and commas never join two words.
+const searchText = `(description || ' ' || tags || ' ' || notes)`
+
+// searchFilter narrows a query to the rows that can match it: for every
+// clause, one of its atoms must have all of its terms in the row's text.
+// LIKE only folds ASCII case, so rows holding any other character are
+// kept and left to the index.
+func searchFilter(q *search.Query) (string, []any) {
+	if q.Empty() {
+		return "", nil
+	}
+	var (
+		clauses []string
+		args    []any
+	)
+	for _, clause := range q.Clauses {
+		var atoms []string
+		for _, atom := range clause {
+			var terms []string
+			for _, term := range atom.Terms {
+				terms = append(terms, searchText+` LIKE ?`)
+				args = append(args, "%"+term+"%")
+			}
+			atoms = append(atoms, "("+strings.Join(terms, " AND ")+")")
+		}
+		clauses = append(clauses, "("+strings.Join(atoms, " OR ")+")")
+	}
+	return `WHERE length(` + searchText + `) != length(CAST(` + searchText + ` AS BLOB))
+		OR (` + strings.Join(clauses, " AND ") + `)`, args
+}
+
+// searchTasks loads the rows searchFilter lets through and ranks them
+// with an index built for the call. Scores are relative to those rows.
+// The server answers searches from CachedStore's index, so this only
+// serves direct use of the store.
+func searchTasks(db querier, query string) ([]models.SearchResult, error) {
+	q, err := search.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	where, args := searchFilter(q)
+	tasks, err := queryTasks(db, where, args...)
+	if err != nil {
+		return nil, err
+	}
+	tm := models.NewTaskManager()
+	tm.Load(tasks)
+	return tm.Search(query)
+}
+
+func (s *SQLiteStore) Get(id int) (*models.Task, error) {
+	return getTask(s.db, id)
+}
+
+func (s *SQLiteStore) List() ([]*models.Task, error) {
+	return queryTasks(s.db, "")
+}
+
+func (s *SQLiteStore) Query(q models.TaskQuery) ([]*models.Task, int, error) {
+	return queryPage(s.db, q)
+}
+
+func (s *SQLiteStore) Create(task *models.Task) error {
+	return insertRow(s.db, task)
+}
+
+func (s *SQLiteStore) Update(task *models.Task) error {
+	return updateRow(s.db, task)
+}
+
+func (s *SQLiteStore) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	var task *models.Task
+	err := s.Atomic(func(tx TaskStore) error {
+		var err error
+		task, err = tx.Modify(id, change)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *SQLiteStore) Delete(id int) error {
+	return deleteRow(s.db, id)
+}
+
+func (s *SQLiteStore) Search(query string) ([]models.SearchResult, error) {
+	return searchTasks(s.db, query)
+}
+
+// Atomic runs fn inside one SQL transaction.
+func (s *SQLiteStore) Atomic(fn func(tx TaskStore) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	if err := fn(sqliteTx{tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteTx is the TaskStore view handed to Atomic callbacks.
+type sqliteTx struct {
+	tx *sql.Tx
+}
+
+func (t sqliteTx) Get(id int) (*models.Task, error)               { return getTask(t.tx, id) }
+func (t sqliteTx) List() ([]*models.Task, error)                  { return queryTasks(t.tx, "") }
+func (t sqliteTx) Create(task *models.Task) error                 { return insertRow(t.tx, task) }
+func (t sqliteTx) Update(task *models.Task) error                 { return updateRow(t.tx, task) }
+func (t sqliteTx) Delete(id int) error                            { return deleteRow(t.tx, id) }
+func (t sqliteTx) Search(q string) ([]models.SearchResult, error) { return searchTasks(t.tx, q) }
+
+func (t sqliteTx) Query(q models.TaskQuery) ([]*models.Task, int, error) {
+	return queryPage(t.tx, q)
+}
+
+func (t sqliteTx) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	return modifyRow(t.tx, id, change)
+}
+
+// Atomic on a transaction joins it rather than nesting.
+func (t sqliteTx) Atomic(fn func(tx TaskStore) error) error {
+	return fn(t)
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"task-api/models"
+	"time"
+)
+
+// SQLiteProjectStore keeps projects in the same database as the tasks
+// of a SQLiteStore. Members have a table of their own, indexed by
+// subject.
+type SQLiteProjectStore struct {
+	db *sql.DB
+}
+
+// Projects returns the project store that shares s's database.
+func (s *SQLiteStore) Projects() *SQLiteProjectStore {
+	return &SQLiteProjectStore{db: s.db}
+}
+
+func getProject(db querier, id int) (*models.Project, error) {
+	var (
+		project   = &models.Project{Members: []models.Member{}}
+		createdAt string
+	)
+	err := db.QueryRow(`SELECT id, name, created_at, version FROM projects WHERE id = ?`, id).
+		Scan(&project.ID, &project.Name, &createdAt, &project.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ProjectNotFoundError{ID: id}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if project.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT subject, role FROM project_members WHERE project_id = ? ORDER BY position`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m models.Member
+		if err := rows.Scan(&m.Subject, &m.Role); err != nil {
+			return nil, err
+		}
+		project.Members = append(project.Members, m)
+	}
+	return project, rows.Err()
+}
+
+// putMembers replaces the stored members of project with its Members.
+func putMembers(db querier, project *models.Project) error {
+	if _, err := db.Exec(`DELETE FROM project_members WHERE project_id = ?`, project.ID); err != nil {
+		return err
+	}
+	for i, m := range project.Members {
+		if _, err := db.Exec(`INSERT INTO project_members (project_id, position, subject, role) VALUES (?, ?, ?, ?)`,
+			project.ID, i, m.Subject, m.Role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteProjectStore) Get(id int) (*models.Project, error) {
+	return getProject(s.db, id)
+}
+
+func (s *SQLiteProjectStore) List() ([]*models.Project, error) {
+	rows, err := s.db.Query(`SELECT id FROM projects ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// One connection serves the whole store, so the rows above must be
+	// closed before loading each project.
+	projects := make([]*models.Project, 0, len(ids))
+	for _, id := range ids {
+		project, err := getProject(s.db, id)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+func (s *SQLiteProjectStore) Create(project *models.Project) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	var id any
+	if project.ID != 0 {
+		id = project.ID
+	}
+	if project.Version == 0 {
+		project.Version = 1
+	}
+	res, err := tx.Exec(`INSERT INTO projects (id, name, created_at, version) VALUES (?, ?, ?, ?)`,
+		id, project.Name, formatTime(&project.CreatedAt), project.Version)
+	if err != nil {
+		return err
+	}
+	newID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	project.ID = int(newID)
+	if err := putMembers(tx, project); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteProjectStore) Modify(id int, change func(project *models.Project) error) (*models.Project, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	project, err := getProject(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	version := project.Version
+	if err := change(project); err != nil {
+		return nil, err
+	}
+	project.ID = id
+	project.Version = version + 1
+	if _, err := tx.Exec(`UPDATE projects SET name = ?, created_at = ?, version = ? WHERE id = ?`,
+		project.Name, formatTime(&project.CreatedAt), project.Version, id); err != nil {
+		return nil, err
+	}
+	if err := putMembers(tx, project); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (s *SQLiteProjectStore) Memberships(subject string) (map[int]models.ProjectRole, error) {
+	rows, err := s.db.Query(`SELECT project_id, role FROM project_members WHERE subject = ?`, subject)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]models.ProjectRole{}
+	for rows.Next() {
+		var (
+			id   int
+			role models.ProjectRole
+		)
+		if err := rows.Scan(&id, &role); err != nil {
+			return nil, err
+		}
+		out[id] = role
+	}
+	return out, rows.Err()
+}
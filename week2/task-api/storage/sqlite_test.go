@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"task-api/models"
+	"testing"
+	"time"
+)
+
+func TestSQLiteStore_CRUDAndSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store.Close()
+
+	// Case 1: Create assigns IDs and round-trips fields
+	a, b := models.NewTask(0, "Buy 100% milk"), models.NewTask(0, "Write report")
+	store.Create(a)
+	store.Create(b)
+	if a.ID != 1 || b.ID != 2 {
+		t.Fatalf("Expected IDs 1 and 2, got %d and %d", a.ID, b.ID)
+	}
+	got, err := store.Get(1)
+	if err != nil || got.Description != a.Description || !got.CreatedAt.Equal(a.CreatedAt) {
+		t.Errorf("Get returned %+v (%v)", got, err)
+	}
+
+	// Case 2: Update completion
+	b.Complete()
+	if err := store.Update(b); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if got, _ := store.Get(2); !got.Completed || got.CompletedAt == nil {
+		t.Error("Update did not persist completion.")
+	}
+
+	// Case 3: Search is case-insensitive and ignores punctuation
+	if res, _ := store.Search("MILK 100%"); len(res) != 1 || res[0].ID != 1 {
+		t.Errorf("Search failed. Expected task 1, got %v", res)
+	}
+	if res, _ := store.Search("milk*"); len(res) != 1 {
+		t.Errorf("Prefix search failed, got %d results", len(res))
+	}
+	// The SQL prefilter matches substrings; the index still wants whole words
+	if res, _ := store.Search("100% OR 10 OR mil"); len(res) != 1 || res[0].ID != 1 {
+		t.Errorf("Expected only task 1, got %v", res)
+	}
+	if res, _ := store.Search("10 OR rep"); len(res) != 0 {
+		t.Errorf("Expected no matches for partial words, got %v", res)
+	}
+	// Rows LIKE cannot fold are left to the index
+	cafe := models.NewTask(0, "ＣＡＦÉ order")
+	store.Create(cafe)
+	if res, _ := store.Search("café"); len(res) != 1 || res[0].ID != cafe.ID {
+		t.Errorf("Expected the fullwidth task, got %v", res)
+	}
+	store.Delete(cafe.ID)
+
+	// Case 4: Missing IDs
+	var nf models.TaskNotFoundError
+	if err := store.Delete(99); !errors.As(err, &nf) {
+		t.Errorf("Expected TaskNotFoundError, got %v", err)
+	}
+
+	// Case 5: Priority, due date, tags, notes and owner round-trip
+	due := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+	rich := models.NewTask(0, "Quarterly report")
+	rich.Priority, rich.DueAt, rich.Tags, rich.Notes = models.PriorityHigh, &due, []string{"work", "finance"}, "Ask Sam"
+	rich.Owner, rich.ProjectID, rich.Assignee = "alice", 7, "bob"
+	store.Create(rich)
+	got, _ = store.Get(rich.ID)
+	if got.Priority != models.PriorityHigh || !got.DueAt.Equal(due) || len(got.Tags) != 2 || got.Notes != "Ask Sam" || got.Owner != "alice" ||
+		got.ProjectID != 7 || got.Assignee != "bob" {
+		t.Errorf("Rich fields did not round-trip: %+v", got)
+	}
+	if res, _ := store.Search("finance"); len(res) != 1 || res[0].ID != rich.ID {
+		t.Errorf("Search by tag failed: %v", res)
+	}
+	store.Delete(rich.ID)
+
+	// Case 6: Reopening runs no migrations twice and keeps data
+	store.Close()
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if tasks, _ := store.List(); len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks after reopen, got %d", len(tasks))
+	}
+}
+
+func TestSQLiteStore_Query(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store.Close()
+
+	tokyo := time.FixedZone("JST", 9*60*60)
+	created := time.Date(2026, 10, 1, 9, 0, 0, 123456789, time.UTC)
+	due := time.Date(2026, 11, 1, 9, 0, 0, 0, tokyo)
+	done := true
+
+	report := models.NewTask(0, "Write report")
+	report.CreatedAt, report.DueAt = created, &due
+	report.Priority, report.Tags, report.Owner = models.PriorityHigh, []string{"Work"}, "alice"
+	groceries := models.NewTask(0, "Buy groceries")
+	groceries.CreatedAt, groceries.ProjectID, groceries.Assignee = created.Add(time.Nanosecond), 7, "bob"
+	groceries.Complete()
+	old := models.NewTask(0, "Old idea")
+	old.CreatedAt = created.Add(-time.Hour)
+	trashed := models.NewTask(0, "Trashed idea")
+	trashed.CreatedAt = created.Add(-2 * time.Hour)
+	trashed.Trash()
+	for _, task := range []*models.Task{report, groceries, old, trashed} {
+		if err := store.Create(task); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+	}
+
+	cases := []struct {
+		name  string
+		q     models.TaskQuery
+		want  []int
+		total int
+	}{
+		{"everything live", models.TaskQuery{Sort: "created_at"}, []int{old.ID, report.ID, groceries.ID}, 3},
+		{"trash included", models.TaskQuery{IncludeDeleted: true, Sort: "created_at"}, []int{trashed.ID, old.ID, report.ID, groceries.ID}, 4},
+		{"owner", models.TaskQuery{Owner: "alice"}, []int{report.ID}, 1},
+		{"project and assignee", models.TaskQuery{ProjectID: 7, Assignee: "bob"}, []int{groceries.ID}, 1},
+		{"completed", models.TaskQuery{Completed: &done}, []int{groceries.ID}, 1},
+		// Stored times compare as text down to the nanosecond, in any zone
+		{"created after", models.TaskQuery{CreatedAfter: &created}, []int{groceries.ID}, 1},
+		{"created before", models.TaskQuery{CreatedBefore: ptr(created.In(tokyo))}, []int{old.ID}, 1},
+		{"created window", models.TaskQuery{CreatedAfter: ptr(created.Add(-time.Nanosecond)), CreatedBefore: ptr(created.Add(time.Nanosecond))}, []int{report.ID}, 1},
+		{"priority and tag", models.TaskQuery{Priority: models.PriorityHigh, Tag: "work"}, []int{report.ID}, 1},
+		{"due window", models.TaskQuery{DueAfter: ptr(due.Add(-time.Second).UTC()), DueBefore: ptr(due.Add(time.Second))}, []int{report.ID}, 1},
+		{"due before excludes undated", models.TaskQuery{DueBefore: ptr(due)}, []int{}, 0},
+		{"where and paging", models.TaskQuery{Where: func(task *models.Task) bool { return task.ID != report.ID }, Limit: 1, Offset: 1}, []int{old.ID}, 2},
+	}
+	for _, c := range cases {
+		tasks, total, err := store.Query(c.q)
+		if err != nil {
+			t.Fatalf("%s: Query failed: %v", c.name, err)
+		}
+		got := []int{}
+		for _, task := range tasks {
+			got = append(got, task.ID)
+		}
+		if total != c.total || !slices.Equal(got, c.want) {
+			t.Errorf("%s: expected %v of %d, got %v of %d", c.name, c.want, c.total, got, total)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
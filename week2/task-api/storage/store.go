@@ -0,0 +1,25 @@
+package storage
+
+import "task-api/models"
+
+// TaskStore is the persistence contract the HTTP handlers depend on.
+// Implementations must return models.TaskNotFoundError for unknown IDs.
+type TaskStore interface {
+	Get(id int) (*models.Task, error)
+	List() ([]*models.Task, error)
+	// Create stores a new task. A zero ID is replaced with the next free ID.
+	Create(task *models.Task) error
+	// Update overwrites the stored task as given, Version included.
+	Update(task *models.Task) error
+	// Modify atomically applies change to the stored task and bumps its
+	// Version. An error from change aborts the write and is returned as is.
+	Modify(id int, change func(task *models.Task) error) (*models.Task, error)
+	Delete(id int) error
+	// Search runs a full-text query (see search.Parse) and returns the
+	// matches best first.
+	Search(query string) ([]models.SearchResult, error)
+	// Atomic runs fn against a transactional view of the store: either
+	// every write fn makes through tx lands, or, when fn returns an
+	// error, none does. Calling Atomic on tx joins the same transaction.
+	Atomic(fn func(tx TaskStore) error) error
+}
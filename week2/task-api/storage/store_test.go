@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"task-api/models"
+	"testing"
+	"time"
+)
+
+// allStores opens every TaskStore implementation on fresh temp storage.
+func allStores(t *testing.T) map[string]TaskStore {
+	t.Helper()
+	dir := t.TempDir()
+
+	sqlite, err := NewSQLiteStore(filepath.Join(dir, "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+
+	wal, err := NewLogStore(filepath.Join(dir, "tasks.log"))
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+	t.Cleanup(func() { wal.Close() })
+
+	cached, err := NewCachedStore(NewMemoryStore(), time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewCachedStore failed: %v", err)
+	}
+	t.Cleanup(func() { cached.Close() })
+
+	return map[string]TaskStore{
+		"memory": NewMemoryStore(),
+		"json":   NewJSONStore(filepath.Join(dir, "tasks.json")),
+		"sqlite": sqlite,
+		"wal":    wal,
+		"cached": cached,
+	}
+}
+
+func TestStores_ModifyIsAtomicAndVersioned(t *testing.T) {
+	for name, store := range allStores(t) {
+		t.Run(name, func(t *testing.T) {
+			task := models.NewTask(0, "Counter")
+			if err := store.Create(task); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			// Case 1: Parallel modifications never lose an update
+			const writers = 10
+			var wg sync.WaitGroup
+			for i := 0; i < writers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := store.Modify(task.ID, func(task *models.Task) error {
+						task.Notes += "x"
+						return nil
+					})
+					if err != nil {
+						t.Errorf("Modify failed: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			got, _ := store.Get(task.ID)
+			if len(got.Notes) != writers || got.Version != 1+writers {
+				t.Errorf("Expected %d notes and version %d, got %q and %d", writers, 1+writers, got.Notes, got.Version)
+			}
+
+			// Case 2: An error from change aborts the write
+			abort := errors.New("abort")
+			if _, err := store.Modify(task.ID, func(task *models.Task) error {
+				task.Description = "Changed"
+				return abort
+			}); !errors.Is(err, abort) {
+				t.Errorf("Expected abort error, got %v", err)
+			}
+			if got, _ := store.Get(task.ID); got.Description != "Counter" || got.Version != 1+writers {
+				t.Errorf("Aborted Modify changed the task: %+v", got)
+			}
+
+			// Case 3: Missing task
+			var nf models.TaskNotFoundError
+			if _, err := store.Modify(999, func(*models.Task) error { return nil }); !errors.As(err, &nf) {
+				t.Errorf("Expected TaskNotFoundError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStores_AtomicIsAllOrNothing(t *testing.T) {
+	for name, store := range allStores(t) {
+		t.Run(name, func(t *testing.T) {
+			keep := models.NewTask(0, "Keep me")
+			if err := store.Create(keep); err != nil {
+				t.Fatalf("Create failed: %v", err)
+			}
+
+			// Case 1: A failing callback leaves nothing behind
+			err := store.Atomic(func(tx TaskStore) error {
+				if err := tx.Create(models.NewTask(0, "Rolled back")); err != nil {
+					return err
+				}
+				if err := tx.Delete(keep.ID); err != nil {
+					return err
+				}
+				return tx.Delete(999)
+			})
+			var nf models.TaskNotFoundError
+			if !errors.As(err, &nf) {
+				t.Fatalf("Expected TaskNotFoundError, got %v", err)
+			}
+			if tasks, _ := store.List(); len(tasks) != 1 || tasks[0].ID != keep.ID {
+				t.Errorf("Rolled back batch changed the store: %+v", tasks)
+			}
+
+			// Case 2: A successful callback applies every write
+			err = store.Atomic(func(tx TaskStore) error {
+				for i := 0; i < 3; i++ {
+					if err := tx.Create(models.NewTask(0, "Imported")); err != nil {
+						return err
+					}
+				}
+				_, err := tx.Modify(keep.ID, func(task *models.Task) error {
+					task.Complete()
+					return nil
+				})
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Atomic failed: %v", err)
+			}
+			tasks, _ := store.List()
+			got, _ := store.Get(keep.ID)
+			if len(tasks) != 4 || !got.Completed || got.Version != 2 {
+				t.Errorf("Expected 4 tasks and completed v2, got %d tasks and %+v", len(tasks), got)
+			}
+		})
+	}
+}
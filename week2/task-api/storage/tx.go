@@ -0,0 +1,88 @@
+package storage
+
+import "task-api/models"
+
+// txOp is one write made inside a managerTx.
+type txOp struct {
+	kind opKind
+	id   int
+	task *models.Task
+}
+
+// managerTx is the TaskStore view handed to Atomic callbacks by the
+// stores that keep a TaskManager. It works on a TaskManager the caller
+// owns and journals every write so the store can persist them once fn
+// has succeeded.
+type managerTx struct {
+	tm  *models.TaskManager
+	ops []txOp
+}
+
+// commit replays the writes made through t onto tm, the TaskManager t
+// was cloned from. tm keeps its search index and only reindexes the
+// tasks that changed, instead of the store swapping in the clone and
+// rebuilding the index from scratch on the next search.
+func (t *managerTx) commit(tm *models.TaskManager) {
+	for _, op := range t.ops {
+		switch op.kind {
+		case opCreate:
+			tm.Insert(copyTask(op.task))
+		case opUpdate:
+			tm.Replace(copyTask(op.task))
+		case opDelete:
+			tm.Delete(op.id)
+		}
+	}
+}
+
+func (t *managerTx) Get(id int) (*models.Task, error) {
+	task := t.tm.Get(id)
+	if task == nil {
+		return nil, models.TaskNotFoundError{ID: id}
+	}
+	return copyTask(task), nil
+}
+
+func (t *managerTx) List() ([]*models.Task, error) {
+	return copyTasks(t.tm.List()), nil
+}
+
+func (t *managerTx) Create(task *models.Task) error {
+	stored := t.tm.Insert(copyTask(task))
+	task.ID = stored.ID
+	t.ops = append(t.ops, txOp{kind: opCreate, id: stored.ID, task: copyTask(stored)})
+	return nil
+}
+
+func (t *managerTx) Update(task *models.Task) error {
+	if !t.tm.Replace(copyTask(task)) {
+		return models.TaskNotFoundError{ID: task.ID}
+	}
+	t.ops = append(t.ops, txOp{kind: opUpdate, id: task.ID, task: copyTask(task)})
+	return nil
+}
+
+func (t *managerTx) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	task, err := t.tm.Modify(id, change)
+	if err != nil {
+		return nil, err
+	}
+	t.ops = append(t.ops, txOp{kind: opUpdate, id: id, task: copyTask(task)})
+	return copyTask(task), nil
+}
+
+func (t *managerTx) Delete(id int) error {
+	if !t.tm.Delete(id) {
+		return models.TaskNotFoundError{ID: id}
+	}
+	t.ops = append(t.ops, txOp{kind: opDelete, id: id})
+	return nil
+}
+
+func (t *managerTx) Search(query string) ([]models.SearchResult, error) {
+	return copyResults(t.tm.Search(query))
+}
+
+func (t *managerTx) Atomic(fn func(tx TaskStore) error) error {
+	return fn(t)
+}
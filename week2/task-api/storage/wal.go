@@ -0,0 +1,477 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"task-api/models"
+	"time"
+)
+
+type EventType string
+
+const (
+	TaskCreated   EventType = "TaskCreated"
+	TaskUpdated   EventType = "TaskUpdated"
+	TaskCompleted EventType = "TaskCompleted"
+	TaskDeleted   EventType = "TaskDeleted"
+	// TaskBatch groups the events of one Atomic call on a single line,
+	// so a torn write drops all of them rather than some.
+	TaskBatch EventType = "TaskBatch"
+
+	ProjectCreated EventType = "ProjectCreated"
+	ProjectUpdated EventType = "ProjectUpdated"
+)
+
+// Event is one line of the write-ahead log.
+type Event struct {
+	Seq  int          `json:"seq"`
+	Type EventType    `json:"type"`
+	At   time.Time    `json:"at"`
+	ID   int          `json:"id"`
+	Task *models.Task `json:"task,omitempty"`
+	// Project is set on project events, whose ID is the project's.
+	Project *models.Project `json:"project,omitempty"`
+	// Events holds the grouped events of a TaskBatch.
+	Events []Event `json:"events,omitempty"`
+}
+
+// snapshot is the compacted state of every event up to LastSeq.
+type snapshot struct {
+	LastSeq  int               `json:"last_seq"`
+	Tasks    []*models.Task    `json:"tasks"`
+	Projects []*models.Project `json:"projects,omitempty"`
+}
+
+// DefaultCompactEvery is how many events LogStore appends before it
+// folds the log into a new snapshot.
+const DefaultCompactEvery = 1000
+
+// LogStore persists tasks as an append-only event log. Each write appends
+// and fsyncs one line instead of rewriting every task. On startup the
+// latest snapshot is loaded and newer events are replayed on top of it.
+// Compaction moves the replayed log aside to path.<seq> so the full
+// change history stays on disk for auditing. Projects share the log;
+// see Projects.
+type LogStore struct {
+	mu       sync.Mutex
+	tm       *models.TaskManager
+	projects *projectSet
+	path     string
+	file     *os.File
+
+	seq           int
+	sinceSnapshot int
+	CompactEvery  int
+
+	// broken is set when a failed append could not be rolled back; the
+	// log's tail is then unknown, so every later write is refused.
+	broken error
+}
+
+func NewLogStore(path string) (*LogStore, error) {
+	s := &LogStore{
+		tm:           models.NewTaskManager(),
+		projects:     newProjectSet(),
+		path:         path,
+		CompactEvery: DefaultCompactEvery,
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.file = f
+	return s, nil
+}
+
+func (s *LogStore) snapshotName() string {
+	return s.path + ".snapshot"
+}
+
+func (s *LogStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *LogStore) loadSnapshot() error {
+	data, err := os.ReadFile(s.snapshotName())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	s.tm.Load(snap.Tasks)
+	s.projects.load(snap.Projects)
+	s.seq = snap.LastSeq
+	return nil
+}
+
+// replay applies every logged event newer than the snapshot. A torn
+// final line from a crash mid-append is cut off; damage anywhere else
+// is reported.
+func (s *LogStore) replay() error {
+	f, err := os.OpenFile(s.path, os.O_RDWR, 0644)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var event Event
+			if err := json.Unmarshal(line, &event); err != nil {
+				if readErr == io.EOF {
+					return f.Truncate(offset)
+				}
+				return fmt.Errorf("%s: corrupt event at byte %d: %w", s.path, offset, err)
+			}
+			if event.Seq > s.seq {
+				s.apply(event)
+				s.seq = event.Seq
+				s.sinceSnapshot++
+			}
+		}
+		offset += int64(len(line))
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// apply folds one event into the in-memory tasks and projects.
+func (s *LogStore) apply(event Event) {
+	switch event.Type {
+	case TaskCreated:
+		s.tm.Insert(copyTask(event.Task))
+	case TaskUpdated:
+		s.tm.Replace(copyTask(event.Task))
+	case TaskCompleted:
+		if task := s.tm.Get(event.ID); task != nil {
+			at := event.At
+			task.Completed = true
+			task.CompletedAt = &at
+			task.Version++
+		}
+	case TaskDeleted:
+		s.tm.Delete(event.ID)
+	case TaskBatch:
+		for _, sub := range event.Events {
+			s.apply(sub)
+		}
+	case ProjectCreated, ProjectUpdated:
+		s.projects.put(event.Project.Clone())
+	}
+}
+
+// record appends an event, fsyncs it, applies it and compacts the log
+// when it has grown past CompactEvery. An error means the event is
+// neither on disk nor applied. Once it is durable the write has
+// succeeded, so a failed compaction is only logged and retried on the
+// next write.
+func (s *LogStore) record(event Event) error {
+	event.Seq = s.seq + 1
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := s.append(append(data, '\n')); err != nil {
+		return err
+	}
+
+	s.apply(event)
+	s.seq = event.Seq
+	s.sinceSnapshot++
+
+	if s.CompactEvery > 0 && s.sinceSnapshot >= s.CompactEvery {
+		if err := s.compact(); err != nil {
+			log.Printf("storage: compacting %s failed: %v", s.path, err)
+		}
+	}
+	return nil
+}
+
+// append writes and fsyncs one line. If either step fails, the log is
+// cut back to its previous length so an event reported as failed does
+// not come back on the next replay.
+func (s *LogStore) append(line []byte) error {
+	if s.broken != nil {
+		return s.broken
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(line)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err == nil {
+		return nil
+	}
+
+	rollbackErr := s.file.Truncate(info.Size())
+	if rollbackErr == nil {
+		rollbackErr = s.file.Sync()
+	}
+	if rollbackErr != nil {
+		s.broken = fmt.Errorf("%s: rolling back a failed append: %w; refusing further writes", s.path, rollbackErr)
+	}
+	return err
+}
+
+// Compact writes a snapshot of the current state and starts a new log.
+func (s *LogStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
+
+func (s *LogStore) compact() error {
+	data, err := json.MarshalIndent(snapshot{LastSeq: s.seq, Tasks: s.tm.Tasks, Projects: s.projects.projects}, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The snapshot lands first; if we crash before the log is rotated,
+	// replay skips the events it already contains by sequence number.
+	if err := WriteFileAtomic(s.snapshotName(), data, 0644); err != nil {
+		return err
+	}
+
+	// The old handle stays open until the new log is in place, so a
+	// failure here leaves the store appending to the log it had.
+	archive := fmt.Sprintf("%s.%08d", s.path, s.seq)
+	if err := os.Rename(s.path, archive); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		if undoErr := os.Rename(archive, s.path); undoErr != nil {
+			// Later events would go to the archive, where replay never
+			// looks.
+			s.broken = fmt.Errorf("%s: restoring the log after a failed rotation: %w; refusing further writes", s.path, undoErr)
+		}
+		return err
+	}
+	s.file.Close()
+	s.file = f
+	s.sinceSnapshot = 0
+	return nil
+}
+
+func (s *LogStore) Get(id int) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task := s.tm.Get(id)
+	if task == nil {
+		return nil, models.TaskNotFoundError{ID: id}
+	}
+	return copyTask(task), nil
+}
+
+func (s *LogStore) List() ([]*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyTasks(s.tm.List()), nil
+}
+
+func (s *LogStore) Create(task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if task.ID == 0 {
+		task.ID = s.tm.NextID
+	}
+	return s.record(Event{Type: TaskCreated, At: time.Now(), ID: task.ID, Task: copyTask(task)})
+}
+
+func (s *LogStore) Update(task *models.Task) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.tm.Get(task.ID)
+	if current == nil {
+		return models.TaskNotFoundError{ID: task.ID}
+	}
+	return s.recordUpdate(current, task)
+}
+
+func (s *LogStore) Modify(id int, change func(task *models.Task) error) (*models.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.tm.Get(id)
+	if current == nil {
+		return nil, models.TaskNotFoundError{ID: id}
+	}
+
+	task := current.Clone()
+	if err := change(task); err != nil {
+		return nil, err
+	}
+	task.ID = id
+	task.Version = current.Version + 1
+	if err := s.recordUpdate(current, task); err != nil {
+		return nil, err
+	}
+	return copyTask(task), nil
+}
+
+// recordUpdate logs the change from current to task. A plain completion
+// is logged as its own event so the history reads like what happened
+// rather than a full overwrite.
+func (s *LogStore) recordUpdate(current, task *models.Task) error {
+	completedOnly := !current.Completed && task.Completed && task.CompletedAt != nil &&
+		task.Version == current.Version+1
+	if completedOnly {
+		check := current.Clone()
+		check.Completed, check.CompletedAt, check.Version = true, task.CompletedAt, task.Version
+		completedOnly = reflect.DeepEqual(check, task)
+	}
+	if completedOnly {
+		return s.record(Event{Type: TaskCompleted, At: *task.CompletedAt, ID: task.ID})
+	}
+	return s.record(Event{Type: TaskUpdated, At: time.Now(), ID: task.ID, Task: copyTask(task)})
+}
+
+func (s *LogStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.tm.Get(id) == nil {
+		return models.TaskNotFoundError{ID: id}
+	}
+	return s.record(Event{Type: TaskDeleted, At: time.Now(), ID: id})
+}
+
+func (s *LogStore) Search(query string) ([]models.SearchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyResults(s.tm.Search(query))
+}
+
+// Atomic runs fn on a copy of the tasks and, if it succeeds, logs all of
+// its writes as one TaskBatch event.
+func (s *LogStore) Atomic(fn func(tx TaskStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &managerTx{tm: s.tm.Clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	events := make([]Event, 0, len(tx.ops))
+	for _, op := range tx.ops {
+		event := Event{At: now, ID: op.id, Task: op.task}
+		switch op.kind {
+		case opCreate:
+			event.Type = TaskCreated
+		case opUpdate:
+			event.Type = TaskUpdated
+		case opDelete:
+			event.Type = TaskDeleted
+		}
+		events = append(events, event)
+	}
+	return s.record(Event{Type: TaskBatch, At: now, Events: events})
+}
+
+// LogProjectStore keeps projects in a LogStore's log as ProjectCreated
+// and ProjectUpdated events, and in its snapshots.
+type LogProjectStore struct {
+	s *LogStore
+}
+
+// Projects returns the project store that shares s's log.
+func (s *LogStore) Projects() *LogProjectStore {
+	return &LogProjectStore{s: s}
+}
+
+func (p *LogProjectStore) Get(id int) (*models.Project, error) {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+	project := p.s.projects.get(id)
+	if project == nil {
+		return nil, models.ProjectNotFoundError{ID: id}
+	}
+	return project.Clone(), nil
+}
+
+func (p *LogProjectStore) List() ([]*models.Project, error) {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+	return p.s.projects.list(), nil
+}
+
+func (p *LogProjectStore) Create(project *models.Project) error {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	if project.ID == 0 {
+		project.ID = p.s.projects.nextID
+	}
+	if project.Version == 0 {
+		project.Version = 1
+	}
+	return p.s.record(Event{Type: ProjectCreated, At: time.Now(), ID: project.ID, Project: project.Clone()})
+}
+
+func (p *LogProjectStore) Modify(id int, change func(project *models.Project) error) (*models.Project, error) {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+
+	current := p.s.projects.get(id)
+	if current == nil {
+		return nil, models.ProjectNotFoundError{ID: id}
+	}
+	project := current.Clone()
+	if err := change(project); err != nil {
+		return nil, err
+	}
+	project.ID = id
+	project.Version = current.Version + 1
+	if err := p.s.record(Event{Type: ProjectUpdated, At: time.Now(), ID: id, Project: project.Clone()}); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+func (p *LogProjectStore) Memberships(subject string) (map[int]models.ProjectRole, error) {
+	p.s.mu.Lock()
+	defer p.s.mu.Unlock()
+	return p.s.projects.memberships(subject), nil
+}
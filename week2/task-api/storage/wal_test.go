@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"task-api/models"
+	"testing"
+)
+
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		json.Unmarshal(scanner.Bytes(), &e)
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestLogStore_AppendAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	store, err := NewLogStore(path)
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+
+	a, b := models.NewTask(0, "Task A"), models.NewTask(0, "Task B")
+	store.Create(a)
+	store.Create(b)
+
+	store.Modify(a.ID, func(task *models.Task) error {
+		task.Complete()
+		return nil
+	})
+	store.Modify(b.ID, func(task *models.Task) error {
+		task.Description = "Task B edited"
+		return nil
+	})
+	store.Delete(a.ID)
+	store.Close()
+
+	// Case 1: One event per change, typed by what happened
+	want := []EventType{TaskCreated, TaskCreated, TaskCompleted, TaskUpdated, TaskDeleted}
+	events := readEvents(t, path)
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(events))
+	}
+	for i, e := range events {
+		if e.Type != want[i] || e.Seq != i+1 {
+			t.Errorf("Event %d: expected %s seq %d, got %s seq %d", i, want[i], i+1, e.Type, e.Seq)
+		}
+	}
+
+	// Case 2: Replay rebuilds the same state
+	store, err = NewLogStore(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+	tasks, _ := store.List()
+	if len(tasks) != 1 || tasks[0].Description != "Task B edited" || tasks[0].Version != 2 {
+		t.Errorf("Unexpected state after replay: %v", tasks)
+	}
+	c := models.NewTask(0, "Task C")
+	store.Create(c)
+	if c.ID != 3 {
+		t.Errorf("Expected next ID 3 after replay, got %d", c.ID)
+	}
+}
+
+func TestLogStore_CompactionAndTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.log")
+	store, _ := NewLogStore(path)
+	store.CompactEvery = 3
+
+	for i := 0; i < 4; i++ {
+		store.Create(models.NewTask(0, "Task"))
+	}
+	store.Close()
+
+	// Case 1: Snapshot written and old log archived for auditing
+	if _, err := os.Stat(path + ".snapshot"); err != nil {
+		t.Errorf("Expected snapshot file: %v", err)
+	}
+	if _, err := os.Stat(path + ".00000003"); err != nil {
+		t.Errorf("Expected archived log segment: %v", err)
+	}
+	if events := readEvents(t, path); len(events) != 1 || events[0].Seq != 4 {
+		t.Errorf("Expected only event 4 in the live log, got %v", events)
+	}
+
+	// Case 2: A torn final line is dropped on replay
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"seq":5,"type":"TaskCre`)
+	f.Close()
+
+	store, err := NewLogStore(path)
+	if err != nil {
+		t.Fatalf("Replay with torn write failed: %v", err)
+	}
+	defer store.Close()
+	if tasks, _ := store.List(); len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks, got %d", len(tasks))
+	}
+	store.Create(models.NewTask(0, "After crash"))
+	if events := readEvents(t, path); len(events) != 2 || events[1].Seq != 5 {
+		t.Errorf("Expected clean log after truncation, got %v", events)
+	}
+}
+
+func TestLogStore_WriteFailures(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.log")
+	store, _ := NewLogStore(path)
+	store.CompactEvery = 2
+
+	// Case 1: A failed compaction does not fail the durable write
+	if err := os.Mkdir(path+".snapshot", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := store.Create(models.NewTask(0, "Task")); err != nil {
+			t.Fatalf("Create %d failed: %v", i, err)
+		}
+	}
+	if events := readEvents(t, path); len(events) != 3 {
+		t.Errorf("Expected the log to keep all 3 events, got %d", len(events))
+	}
+
+	// Case 2: Compaction is retried on the next write once it can succeed
+	os.Remove(path + ".snapshot")
+	store.Create(models.NewTask(0, "Task"))
+	if events := readEvents(t, path); len(events) != 0 {
+		t.Errorf("Expected a fresh log after compaction, got %v", events)
+	}
+	store.Create(models.NewTask(0, "Task"))
+
+	// Case 3: An append that fails and cannot be rolled back stops all
+	// writes instead of leaving a half-written log behind
+	good := store.file
+	store.file, _ = os.Open(path)
+	if err := store.Create(models.NewTask(0, "Lost")); err == nil {
+		t.Fatal("Expected Create on a read-only log to fail")
+	}
+	store.file.Close()
+	store.file = good
+	if err := store.Create(models.NewTask(0, "Refused")); err == nil {
+		t.Error("Expected writes to be refused after a failed rollback")
+	}
+	store.Close()
+
+	store, err := NewLogStore(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+	if tasks, _ := store.List(); len(tasks) != 5 {
+		t.Errorf("Expected the 5 acknowledged tasks, got %d", len(tasks))
+	}
+}
+
+func TestLogStore_AtomicIsOneEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.log")
+	store, err := NewLogStore(path)
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+
+	store.Atomic(func(tx TaskStore) error {
+		tx.Create(models.NewTask(0, "Task A"))
+		tx.Create(models.NewTask(0, "Task B"))
+		return tx.Delete(1)
+	})
+	store.Close()
+
+	// Case 1: The whole batch is a single line
+	events := readEvents(t, path)
+	if len(events) != 1 || events[0].Type != TaskBatch || len(events[0].Events) != 3 {
+		t.Fatalf("Expected one TaskBatch of 3 events, got %+v", events)
+	}
+
+	// Case 2: Replay applies the grouped events in order
+	store, err = NewLogStore(path)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer store.Close()
+	tasks, _ := store.List()
+	if len(tasks) != 1 || tasks[0].Description != "Task B" {
+		t.Errorf("Unexpected state after replay: %v", tasks)
+	}
+}
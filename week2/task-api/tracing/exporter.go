@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// errExporterClosed is returned by exporters used after Shutdown.
+var errExporterClosed = errors.New("tracing: exporter is shut down")
+
+// WriterExporter writes each span as one line of JSON, for local use
+// and for feeding a collector that tails files.
+type WriterExporter struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+	closed bool
+}
+
+// NewWriterExporter exports to w, which Shutdown leaves open.
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(w)}
+}
+
+// NewFileExporter appends spans to the file at path, creating it if
+// needed. Shutdown closes the file.
+func NewFileExporter(path string) (*WriterExporter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &WriterExporter{enc: json.NewEncoder(f), closer: f}, nil
+}
+
+func (e *WriterExporter) Export(span *SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errExporterClosed
+	}
+	return e.enc.Encode(span)
+}
+
+func (e *WriterExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
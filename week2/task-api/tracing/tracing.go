@@ -0,0 +1,296 @@
+// Package tracing records spans in the shape OpenTelemetry uses and
+// propagates them with W3C Trace Context (traceparent) headers, so
+// task-api shows up in end-to-end traces started by other services.
+// Finished spans go to a pluggable Exporter.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceparentHeader carries the caller's span (W3C Trace Context).
+const TraceparentHeader = "traceparent"
+
+// TraceID identifies a trace across services.
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies one span within a trace.
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// Traceparent formats sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent reads a traceparent header value. Later versions are
+// accepted as long as they start with the version 00 fields.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("traceparent %q: want version-traceid-spanid-flags", s)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || version == "ff" || !isLowerHex(version) ||
+		(version == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("traceparent %q: bad version", s)
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) || len(spanID) != 16 || !isLowerHex(spanID) ||
+		len(flags) != 2 || !isLowerHex(flags) {
+		return sc, fmt.Errorf("traceparent %q: bad field", s)
+	}
+	hex.Decode(sc.TraceID[:], []byte(traceID))
+	hex.Decode(sc.SpanID[:], []byte(spanID))
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("traceparent %q: all-zero ID", s)
+	}
+	var f [1]byte
+	hex.Decode(f[:], []byte(flags))
+	sc.Sampled = f[0]&1 == 1
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !('0' <= s[i] && s[i] <= '9' || 'a' <= s[i] && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Kind says what a span stands for, as in OpenTelemetry.
+type Kind string
+
+const (
+	KindServer   Kind = "server"
+	KindInternal Kind = "internal"
+)
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	Name         string         `json:"name"`
+	Kind         Kind           `json:"kind"`
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	// Status is "ok" or "error"; Error describes the failure.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Exporter ships finished spans somewhere. Export is called from
+// whichever goroutine ends the span and must be safe for concurrent use.
+type Exporter interface {
+	Export(span *SpanData) error
+	// Shutdown flushes and releases the exporter.
+	Shutdown(ctx context.Context) error
+}
+
+// Span is a span in progress. Its methods are safe for concurrent use
+// and do nothing once the span has ended.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+// SpanContext returns the IDs that children and downstream services
+// refer to.
+func (s *Span) SpanContext() SpanContext { return s.sc }
+
+// SetName renames the span, e.g. once the route is known.
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Name = name
+	}
+}
+
+// SetAttribute attaches a key/value pair to the span.
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]any{}
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span as failed with err; a nil err changes nothing.
+func (s *Span) SetError(err error) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Status, s.data.Error = "error", err.Error()
+	}
+}
+
+// End finishes the span now and exports it if it is sampled.
+func (s *Span) End() { s.EndAt(time.Now()) }
+
+// EndAt finishes the span at t and exports it if it is sampled.
+func (s *Span) EndAt(t time.Time) {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = t
+	data := s.data
+	s.mu.Unlock()
+
+	if s.sc.Sampled && s.tracer != nil {
+		s.tracer.export(&data)
+	}
+}
+
+type spanKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span, which becomes the
+// parent of spans started from it.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+type remoteKey struct{}
+
+// ContextWithRemote returns a copy of ctx whose next span continues the
+// trace sc received from another service.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// Tracer starts spans and hands them to its exporter when they end.
+// A nil *Tracer is valid: its spans get IDs but are never exported.
+type Tracer struct {
+	exporter Exporter
+	onError  func(error)
+}
+
+// NewTracer returns a Tracer exporting to exporter. Export failures are
+// passed to onError, which may be nil.
+func NewTracer(exporter Exporter, onError func(error)) *Tracer {
+	return &Tracer{exporter: exporter, onError: onError}
+}
+
+// SpanOption adjusts a span as it starts.
+type SpanOption func(*Span)
+
+// WithKind sets the span kind; the default is KindInternal.
+func WithKind(kind Kind) SpanOption {
+	return func(s *Span) { s.data.Kind = kind }
+}
+
+// WithStart backdates the span, for work that was timed elsewhere.
+func WithStart(t time.Time) SpanOption {
+	return func(s *Span) { s.data.Start = t }
+}
+
+// Start begins a span named name. It is a child of the span in ctx, or of
+// the remote span stored by ContextWithRemote, or else the root of a new
+// sampled trace. The returned context carries the new span.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...SpanOption) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		data:   SpanData{Name: name, Kind: KindInternal, Start: time.Now(), Status: "ok"},
+	}
+
+	var parent SpanContext
+	if p := SpanFromContext(ctx); p != nil {
+		parent = p.sc
+	} else if remote, ok := ctx.Value(remoteKey{}).(SpanContext); ok {
+		parent = remote
+	}
+	if parent.IsValid() {
+		span.sc.TraceID, span.sc.Sampled = parent.TraceID, parent.Sampled
+		span.data.ParentSpanID = parent.SpanID.String()
+	} else {
+		rand.Read(span.sc.TraceID[:])
+		span.sc.Sampled = true
+	}
+	rand.Read(span.sc.SpanID[:])
+	span.data.TraceID, span.data.SpanID = span.sc.TraceID.String(), span.sc.SpanID.String()
+
+	for _, opt := range opts {
+		opt(span)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+func (t *Tracer) export(data *SpanData) {
+	if t.exporter == nil {
+		return
+	}
+	if err := t.exporter.Export(data); err != nil && t.onError != nil {
+		t.onError(err)
+	}
+}
+
+// Shutdown flushes the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// ObserveStorage records a storage operation that has just finished as
+// a span named storage.<op>, a child of the span in ctx; it fits
+// storage.ObservedStore.Observe. Backend calls happen at startup and in
+// the write-behind flush, outside any request, so they start traces of
+// their own.
+func (t *Tracer) ObserveStorage(ctx context.Context, op string, d time.Duration, err error) {
+	end := time.Now()
+	_, span := t.Start(ctx, "storage."+op, WithStart(end.Add(-d)))
+	span.SetAttribute("storage.operation", op)
+	span.SetError(err)
+	span.EndAt(end)
+}
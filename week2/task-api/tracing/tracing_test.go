@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	// Case 1: A sampled version 00 header round-trips
+	const header = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(header)
+	if err != nil {
+		t.Fatalf("ParseTraceparent failed: %v", err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled {
+		t.Errorf("Unexpected span context: %+v", sc)
+	}
+	if sc.Traceparent() != header {
+		t.Errorf("Expected %q, got %q", header, sc.Traceparent())
+	}
+
+	// Case 2: Future versions may append fields; flags other than sampled are ignored
+	sc, err = ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-02-extra")
+	if err != nil || sc.Sampled {
+		t.Errorf("Expected unsampled future-version context, got %+v (%v)", sc, err)
+	}
+
+	// Case 3: Malformed headers are rejected
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&buf), nil)
+	spans := func() []SpanData {
+		var out []SpanData
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var span SpanData
+			if err := json.Unmarshal([]byte(line), &span); err != nil {
+				t.Fatalf("Bad span line %q: %v", line, err)
+			}
+			out = append(out, span)
+		}
+		buf.Reset()
+		return out
+	}
+
+	// Case 1: Children join their parent's trace and are exported first
+	ctx, root := tracer.Start(context.Background(), "root", WithKind(KindServer))
+	_, child := tracer.Start(ctx, "child")
+	child.SetAttribute("n", 1)
+	child.SetError(errors.New("boom"))
+	child.End()
+	root.End()
+	root.SetName("ignored after End")
+	root.End()
+
+	got := spans()
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(got))
+	}
+	if got[0].Name != "child" || got[0].TraceID != got[1].TraceID || got[0].ParentSpanID != got[1].SpanID ||
+		got[0].Status != "error" || got[0].Error != "boom" || got[0].Attributes["n"] != float64(1) {
+		t.Errorf("Unexpected child span: %+v", got[0])
+	}
+	if got[1].Name != "root" || got[1].Kind != KindServer || got[1].ParentSpanID != "" || got[1].Status != "ok" {
+		t.Errorf("Unexpected root span: %+v", got[1])
+	}
+
+	// Case 2: A remote parent continues the caller's trace and sampling
+	remote, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, span := tracer.Start(ContextWithRemote(context.Background(), remote), "server")
+	span.End()
+	if got := spans(); got[0].TraceID != remote.TraceID.String() || got[0].ParentSpanID != remote.SpanID.String() {
+		t.Errorf("Remote parent not used: %+v", got[0])
+	}
+	remote.Sampled = false
+	_, span = tracer.Start(ContextWithRemote(context.Background(), remote), "unsampled")
+	span.End()
+	if buf.Len() != 0 {
+		t.Errorf("Unsampled span was exported: %s", buf.String())
+	}
+
+	// Case 3: Storage observations become backdated spans, roots outside
+	// a request and children inside one
+	tracer.ObserveStorage(context.Background(), "save", 50*time.Millisecond, errors.New("disk full"))
+	got = spans()
+	if got[0].Name != "storage.save" || got[0].ParentSpanID != "" || got[0].Error != "disk full" ||
+		got[0].End.Sub(got[0].Start) != 50*time.Millisecond {
+		t.Errorf("Unexpected storage span: %+v", got[0])
+	}
+	ctx, request := tracer.Start(context.Background(), "GET /tasks")
+	tracer.ObserveStorage(ctx, "query", time.Millisecond, nil)
+	got = spans()
+	if sc := request.SpanContext(); got[0].Name != "storage.query" || got[0].ParentSpanID != sc.SpanID.String() ||
+		got[0].TraceID != sc.TraceID.String() {
+		t.Errorf("Expected a child of the request span, got %+v", got[0])
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := NewFileExporter(path)
+	if err != nil {
+		t.Fatalf("NewFileExporter failed: %v", err)
+	}
+	tracer := NewTracer(exporter, nil)
+	_, span := tracer.Start(context.Background(), "work")
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"name":"work"`) {
+		t.Errorf("Span not written: %s", data)
+	}
+	if err := exporter.Export(&SpanData{}); err == nil {
+		t.Error("Expected Export after Shutdown to fail")
+	}
+}
@@ -0,0 +1,196 @@
+// Package validate checks structs against their `validate` struct tags.
+//
+// Supported rules, separated by commas:
+//
+//	required   value must be set (non-blank string, non-nil pointer, non-empty slice)
+//	omitempty  skip the remaining rules when the value is not set; a
+//	           non-nil pointer counts as set, so PATCH bodies can tell an
+//	           absent field from a blank one
+//	min=N      minimum length for strings and slices, minimum value for numbers
+//	max=N      maximum length for strings and slices, maximum value for numbers
+//	oneof=a b  value must be one of the space separated options
+//	rfc3339    string must parse as an RFC 3339 timestamp
+//	dive       apply the remaining rules to every element of a slice
+//
+// Pointers are followed, so a *string field tagged "omitempty,min=3" is
+// only checked when present.
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes one broken rule.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors collects every FieldError found in a struct.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct validates every tagged field of v, which must be a struct or a
+// pointer to one. It returns nil or an Errors value listing all failures.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: expected struct, got %T", v))
+	}
+
+	var errs Errors
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		errs = append(errs, checkValue(jsonName(field), rv.Field(i), strings.Split(tag, ","))...)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// jsonName reports a field by the name clients send.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// deref follows pointers until it reaches a value or a nil pointer.
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+func isSet(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return !v.IsNil()
+	case reflect.String:
+		return strings.TrimSpace(v.String()) != ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() > 0
+	}
+	return !v.IsZero()
+}
+
+func checkValue(name string, v reflect.Value, rules []string) Errors {
+	var errs Errors
+	for i, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		key, param, _ := strings.Cut(rule, "=")
+
+		switch key {
+		case "":
+			continue
+		case "required":
+			if !isSet(deref(v)) {
+				return append(errs, FieldError{name, key, name + " is required"})
+			}
+			continue
+		case "omitempty":
+			if !isSet(v) {
+				return errs
+			}
+			continue
+		}
+
+		// Every other rule looks at the value behind any pointer.
+		if v = deref(v); v.Kind() == reflect.Pointer {
+			return errs
+		}
+
+		if key == "dive" {
+			if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+				panic("validate: dive on non-slice field " + name)
+			}
+			for j := 0; j < v.Len(); j++ {
+				errs = append(errs, checkValue(fmt.Sprintf("%s[%d]", name, j), v.Index(j), rules[i+1:])...)
+			}
+			return errs
+		}
+
+		if msg := checkRule(name, key, param, v); msg != "" {
+			errs = append(errs, FieldError{name, key, msg})
+		}
+	}
+	return errs
+}
+
+// checkRule returns an error message when v breaks key=param.
+func checkRule(name, key, param string, v reflect.Value) string {
+	switch key {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad %s=%q on %s", key, param, name))
+		}
+		n, unit := measure(v)
+		if key == "min" && n < limit {
+			return fmt.Sprintf("%s must be at least %s%s", name, param, unit)
+		}
+		if key == "max" && n > limit {
+			return fmt.Sprintf("%s must be at most %s%s", name, param, unit)
+		}
+
+	case "oneof":
+		options := strings.Fields(param)
+		value := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if value == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%s must be one of %s", name, strings.Join(options, ", "))
+
+	case "rfc3339":
+		if _, err := time.Parse(time.RFC3339, v.String()); err != nil {
+			return fmt.Sprintf("%s must be an RFC 3339 timestamp", name)
+		}
+
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q on %s", key, name))
+	}
+	return ""
+}
+
+// measure returns the number min/max compare against and the unit used
+// in messages.
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(strings.TrimSpace(v.String()))), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+	panic("validate: min/max on unsupported kind " + v.Kind().String())
+}
@@ -0,0 +1,75 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+)
+
+type sample struct {
+	Name     string   `json:"name" validate:"required,min=3,max=5"`
+	Level    string   `json:"level" validate:"omitempty,oneof=low high"`
+	Count    int      `json:"count" validate:"min=1,max=10"`
+	When     string   `json:"when" validate:"omitempty,rfc3339"`
+	Tags     []string `json:"tags" validate:"max=2,dive,min=2"`
+	Nickname *string  `json:"nickname" validate:"omitempty,required,min=2"`
+	Ignored  string
+}
+
+func rules(err error) map[string]string {
+	var errs Errors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	out := map[string]string{}
+	for _, fe := range errs {
+		out[fe.Field] = fe.Rule
+	}
+	return out
+}
+
+func TestStruct(t *testing.T) {
+	blank, short := "  ", "x"
+
+	cases := []struct {
+		name  string
+		input sample
+		want  map[string]string
+	}{
+		{"valid", sample{Name: "abc", Count: 1, Tags: []string{"ok"}}, nil},
+		{"required blank", sample{Name: "   ", Count: 1}, map[string]string{"name": "required"}},
+		{"min and max", sample{Name: "ab", Count: 11}, map[string]string{"name": "min", "count": "max"}},
+		{"oneof", sample{Name: "abc", Level: "mid", Count: 1}, map[string]string{"level": "oneof"}},
+		{"rfc3339", sample{Name: "abc", Count: 1, When: "tomorrow"}, map[string]string{"when": "rfc3339"}},
+		{"dive", sample{Name: "abc", Count: 1, Tags: []string{"ok", "x"}}, map[string]string{"tags[1]": "min"}},
+		{"slice max", sample{Name: "abc", Count: 1, Tags: []string{"aa", "bb", "cc"}}, map[string]string{"tags": "max"}},
+		{"nil pointer skipped", sample{Name: "abc", Count: 1, Nickname: nil}, nil},
+		{"blank pointer required", sample{Name: "abc", Count: 1, Nickname: &blank}, map[string]string{"nickname": "required"}},
+		{"short pointer", sample{Name: "abc", Count: 1, Nickname: &short}, map[string]string{"nickname": "min"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := Struct(&c.input)
+			got := rules(err)
+			if len(got) != len(c.want) {
+				t.Fatalf("Expected %v, got %v (%v)", c.want, got, err)
+			}
+			for field, rule := range c.want {
+				if got[field] != rule {
+					t.Errorf("Field %s: expected rule %q, got %q", field, rule, got[field])
+				}
+			}
+		})
+	}
+}
+
+func TestStruct_MessagesUseJSONNames(t *testing.T) {
+	err := Struct(sample{Name: "", Count: 0})
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", err)
+	}
+	if errs[0].Message != "name is required" || errs[1].Message != "count must be at least 1" {
+		t.Errorf("Unexpected messages: %q, %q", errs[0].Message, errs[1].Message)
+	}
+}